@@ -0,0 +1,72 @@
+/*
+Copyright (c) 2015 Matthias S. Benkmann
+
+This program is free software; you can redistribute it and/or
+modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation; version 3
+of the License (ONLY this version).
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+*/
+
+/*
+  This file contains the code for checking the contents of the restricted
+  character string types (X.680 section 41) against their permitted alphabets.
+*/
+
+package asn1
+
+import (
+         "fmt"
+         "unicode/utf8"
+       )
+
+// Universal tags of the restricted character string types whose alphabet is checked.
+const (
+  NumericStringTag = 18
+  PrintableStringTag = 19
+  IA5StringTag = 22
+  VisibleStringTag = 26
+)
+
+// Returns true iff c is permitted in a PrintableString.
+func printableChar(c byte) bool {
+  switch {
+    case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9': return true
+  }
+  switch c {
+    case ' ', '\'', '(', ')', '+', ',', '-', '.', '/', ':', '=', '?': return true
+  }
+  return false
+}
+
+// Maps the universal tag of a restricted character string type to a function that
+// returns true iff a byte is permitted in that type.
+var charsets = map[int]func(c byte) bool {
+  NumericStringTag:   func(c byte) bool { return c == ' ' || (c >= '0' && c <= '9') },
+  PrintableStringTag: printableChar,
+  IA5StringTag:       func(c byte) bool { return c < 128 },
+  VisibleStringTag:   func(c byte) bool { return c >= ' ' && c <= '~' },
+}
+
+// Returns an error if data contains a character that is not permitted in the
+// restricted character string type with the universal tag (see Tree.stringTag).
+// Types whose alphabet is not checked are always accepted.
+func checkCharset(tag int, data []byte) error {
+  permitted := charsets[tag]
+  if permitted == nil { return nil }
+  for i, c := range data {
+    if !permitted(c) {
+      r := rune(c)
+      if c >= 128 { r, _ = utf8.DecodeRune(data[i:]) }
+      if r != utf8.RuneError && (r >= 128 || (c >= ' ' && c <= '~')) {
+        return fmt.Errorf("Character %q at offset %v is not permitted in %v: %q", r, i, UniversalTagName[tag], data)
+      }
+      return fmt.Errorf("Byte 0x%02X at offset %v is not permitted in %v: %q", c, i, UniversalTagName[tag], data)
+    }
+  }
+  return nil
+}
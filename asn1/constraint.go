@@ -0,0 +1,174 @@
+/*
+Copyright (c) 2015 Matthias S. Benkmann
+
+This program is free software; you can redistribute it and/or
+modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation; version 3
+of the License (ONLY this version).
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+*/
+
+/*
+  This file contains the code for resolving SIZE and value range constraints
+  such as "(SIZE (1..ub-name))" or "(0..MAX)" and for checking instances against them.
+*/
+
+package asn1
+
+import (
+         "fmt"
+         "strings"
+         "math/big"
+         "unicode/utf8"
+       )
+
+// A SIZE or value range constraint.
+type constraint struct {
+  // The constraint from the ASN.1 source without the surrounding "(SIZE (...))" or "(...)",
+  // e.g. "1..ub-name" or "2".
+  src string
+
+  // The character index in the ASN.1 source of the constraint.
+  pos int
+
+  // True if lo and hi have been filled in from src.
+  resolved bool
+
+  // The lower and upper bound (inclusive). nil means MIN or MAX respectively.
+  lo, hi *big.Int
+}
+
+// Returns the constraint in ASN.1 syntax, e.g. "1..64" or "0..MAX".
+func (c *constraint) String() string {
+  lo := "MIN"
+  hi := "MAX"
+  if c.lo != nil { lo = c.lo.String() }
+  if c.hi != nil { hi = c.hi.String() }
+  if c.lo != nil && c.hi != nil && c.lo.Cmp(c.hi) == 0 { return lo }
+  return lo + ".." + hi
+}
+
+// Returns true iff lo <= x <= hi.
+func (c *constraint) contains(x *big.Int) bool {
+  return (c.lo == nil || c.lo.Cmp(x) <= 0) && (c.hi == nil || x.Cmp(c.hi) <= 0)
+}
+
+// Returns the constraint that is satisfied by exactly the values that satisfy
+// both c and c2. Either of them may be nil which means "no constraint".
+func (c *constraint) intersect(c2 *constraint) *constraint {
+  if c == nil { return c2 }
+  if c2 == nil { return c }
+  result := &constraint{src:c.src, pos:c.pos, resolved:true, lo:c.lo, hi:c.hi}
+  if result.lo == nil || (c2.lo != nil && c2.lo.Cmp(result.lo) > 0) { result.lo = c2.lo }
+  if result.hi == nil || (c2.hi != nil && c2.hi.Cmp(result.hi) < 0) { result.hi = c2.hi }
+  return result
+}
+
+// Fills in c.lo and c.hi from c.src. t is the node the constraint belongs to.
+func (d *Definitions) resolveConstraint(t *Tree, c *constraint) error {
+  if c == nil || c.resolved { return nil }
+  bounds := strings.Split(c.src, "..")
+  if len(bounds) > 2 {
+    return NewParseError(t.src, c.pos, "Unsupported constraint: %v", c.src)
+  }
+  for i, b := range bounds {
+    b = strings.TrimSpace(b)
+    var bound *big.Int
+    if b == "MIN" || b == "MAX" {
+      if (b == "MIN") != (i == 0 && len(bounds) == 2) {
+        return NewParseError(t.src, c.pos, "Unsupported constraint: %v", c.src)
+      }
+    } else if integer.MatchString(b) {
+      bound, _ = new(big.Int).SetString(b, 10)
+    } else if v, ok := d.lookupValue(t, b); ok {
+      switch val := v.value.(type) {
+        case int: bound = big.NewInt(int64(val))
+        case *big.Int: bound = val
+        default: return NewParseError(t.src, c.pos, "Constraint refers to value '%v' which is not an INTEGER", b)
+      }
+    } else if tokValueReference.Regex.MatchString(b) {
+      return NewParseError(t.src, c.pos, "Constraint refers to unknown value '%v'", b)
+    } else {
+      return NewParseError(t.src, c.pos, "Unsupported constraint: %v", c.src)
+    }
+    if i == 0 { c.lo = bound }
+    if i == len(bounds)-1 { c.hi = bound }
+  }
+  c.resolved = true
+  return nil
+}
+
+// Resolves the constraints of t and combines them with the constraints of typ
+// (which must already be resolved) if typ != nil.
+func (d *Definitions) resolveConstraints(t *Tree, typ *Tree) error {
+  if err := d.resolveConstraint(t, t.size); err != nil { return err }
+  if err := d.resolveConstraint(t, t.valueRange); err != nil { return err }
+  if typ != nil {
+    t.size = t.size.intersect(typ.size)
+    t.valueRange = t.valueRange.intersect(typ.valueRange)
+  }
+  return nil
+}
+
+// Resolves the constraints of the type definition t (after those of
+// the type it refers to). resolved tracks the definitions that have already been processed.
+func (d *Definitions) resolveTypeConstraints(t *Tree, resolved map[*Tree]bool) error {
+  if resolved[t] { return nil }
+  resolved[t] = true
+  typ := d.typedefs[t.typename]
+  if typ != nil {
+    if err := d.resolveTypeConstraints(typ, resolved); err != nil { return err }
+  }
+  return d.resolveConstraints(t, typ)
+}
+
+// Returns the size of inst as relevant for a SIZE constraint, i.e. the number of
+// characters for character strings, the number of octets for other OCTET STRINGs,
+// the number of bits for BIT STRINGs and the number of elements for SEQUENCE OF and SET OF.
+// Returns false if inst is of a type that has no size.
+func constraintSize(inst *Instance) (int, bool) {
+  switch inst.basictype {
+    case OCTET_STRING:
+      v, _ := inst.value.([]byte)
+      if inst.stringTag != 0 && inst.stringTag != BasicTypeTag[OCTET_STRING] {
+        return utf8.RuneCount(v), true
+      }
+      return len(v), true
+    case BIT_STRING:
+      v, _ := inst.value.([]bool)
+      return len(v), true
+    case SEQUENCE_OF, SET_OF:
+      return len(inst.children), true
+  }
+  return 0, false
+}
+
+// Returns an error if inst (which has been instantiated from t) violates a constraint of t
+// or contains a character that is not permitted in its restricted character string type.
+func (t *Tree) checkConstraints(inst *Instance, p *pathNode) error {
+  if t.size != nil {
+    if size, ok := constraintSize(inst); ok && !t.size.contains(big.NewInt(int64(size))) {
+      return fmt.Errorf("%vSize %v violates constraint (SIZE (%v))", p, size, t.size)
+    }
+  }
+  if t.valueRange != nil {
+    var value *big.Int
+    switch v := inst.value.(type) {
+      case int: value = big.NewInt(int64(v))
+      case *big.Int: value = v
+    }
+    if value != nil && (inst.basictype == INTEGER || inst.basictype == ENUMERATED) && !t.valueRange.contains(value) {
+      return fmt.Errorf("%vValue %v violates constraint (%v)", p, value, t.valueRange)
+    }
+  }
+  if v, ok := inst.value.([]byte); ok {
+    if err := checkCharset(inst.stringTag, v); err != nil {
+      return fmt.Errorf("%v%v", p, err)
+    }
+  }
+  return nil
+}
@@ -76,6 +76,9 @@ type CookStackFunc func(stack *[]*CookStackElement, location string) error
     are searched from the last (i.e. i=len(vars)-1) to the first. So they represent nested
     scopes of variables.
   * Function name found in funcs. The function is called on the stack.
+  * field(path) where path is a list of field names separated by ".". The top element of
+    the stack, which must be an *Instance, is replaced with its component identified by
+    path (see Instance.Field()), e.g. field(tbsCertificate.subject).
   * String literal enclosed in single-quotes '...'. To include a single quote in the string,
     double it (i.e. write ''). Note that within the program '' is the empty string.
     '''' is a string consisting of only a single single-quote.
@@ -235,6 +238,8 @@ var integerSequence = regexp.MustCompile("^[0-9]{1,9}([.][0-9]{1,9}){2,}$")
 
 var integer = regexp.MustCompile("^[+-]?[0-9]+$")
 
+var fieldAccess = regexp.MustCompile(`^field\(([^()]+)\)$`)
+
 var errReordered = fmt.Errorf("Reordered => Reset")
 
 func path2Location(path []string) string {
@@ -267,6 +272,7 @@ func exec_program(defs *Definitions, vars []map[string]interface{}, scopes []*co
     } else if defs.HasValue(f) {
     } else if integerSequence.MatchString(f) {
     } else if integer.MatchString(f) {
+    } else if fieldAccess.MatchString(f) {
     } else {
       i := len(scopes)-1
       for ; i >= 0; i-- {
@@ -344,6 +350,19 @@ func exec_program(defs *Definitions, vars []map[string]interface{}, scopes []*co
         return nil, fmt.Errorf("%vError parsing INTEGER: %v", path2Location(path), f)
       }
       stack = append(stack, &CookStackElement{Value:&b})
+    } else if m := fieldAccess.FindStringSubmatch(f); m != nil {
+      if len(stack) == 0 {
+        return nil, fmt.Errorf("%v%v called on empty stack", path2Location(path), f)
+      }
+      inst, ok := stack[len(stack)-1].Value.(*Instance)
+      if !ok {
+        return nil, fmt.Errorf("%v%v requires top element of stack to be an instance of an ASN.1 type", path2Location(path), f)
+      }
+      field, err := inst.Field(m[1])
+      if err != nil {
+        return nil, fmt.Errorf("%v%v: %v", path2Location(path), f, err)
+      }
+      stack[len(stack)-1] = &CookStackElement{Value:field}
     } else {
       i := len(scopes)-1
       for ; i >= 0; i-- {
@@ -25,11 +25,13 @@ import (
          "os"
          "fmt"
          "strings"
+         "sort"
          "math/big"
          "crypto"
          "crypto/x509"
          "crypto/rsa"
          "crypto/ecdsa"
+         "crypto/ed25519"
          "winterdrache.de/golib/util"
          "winterdrache.de/golib/deque"
        )
@@ -115,7 +117,7 @@ func encodeDER(b *[]byte, t *Tree) {
         }
       
       case OCTET_STRING:
-        if t.tags[len(t.tags)-2] == 30 { // BMPString; -2 because the last byte of tags is 0
+        if t.stringTag == 30 { // BMPString
           *b = append(*b, encodeUTF16(t.value.([]byte))...)
         } else {
           *b = append(*b, t.value.([]byte)...)
@@ -193,8 +195,14 @@ func encodeDER(b *[]byte, t *Tree) {
     }
   }
   
-  // fill in all length placeholders with actual length
-  for datastart > start {
+  // fill in all length placeholders with actual length, starting with the innermost
+  placeholders := []int{} // the positions following the placeholders
+  for i := 0; i < len(t.tags); {
+    i += tagLength(t.tags[i:]) + 1
+    placeholders = append(placeholders, start+i)
+  }
+  for k := len(placeholders)-1; k >= 0; k-- {
+    datastart = placeholders[k]
     length := len(*b) - datastart
     if length <= 127 { // length can be encoded in the 1 byte already reserved
       (*b)[datastart-1] = byte(length)
@@ -211,10 +219,6 @@ func encodeDER(b *[]byte, t *Tree) {
       copy(newb[datastart:], le)
       *b = newb
     }
-    
-    // find next length placeholder
-    datastart--
-    for datastart > start && (*b)[datastart-1] != 0 { datastart-- }
   }
 }
 
@@ -297,11 +301,6 @@ func analyseDER(der []byte, idx int, indent string, output *[]string) int {
         }
         *output = append(*output, fmt.Sprintf(" INDEFINITE LENGTH"))
       } else {
-        if length > 3 { // reject data structures larger than 16MB (or incorrectly encoded length)
-          *output = append(*output, " !TOO MANY LENGTH OCTETS!")
-          return idx
-        }
-        
         l := 0
         for length > 0 {
           idx++
@@ -309,6 +308,10 @@ func analyseDER(der []byte, idx int, indent string, output *[]string) int {
             *output = append(*output, prematureEnd)
             return idx
           }
+          if l > len(der) { // cannot fit anyway; stop before l overflows
+            *output = append(*output, " !LENGTH EXCEEDS AVAILABLE DATA!")
+            return idx
+          }
           *output = append(*output, fmt.Sprintf(" %02X", der[idx]))
           l = (l << 8) + int(der[idx])
           length--
@@ -443,10 +446,19 @@ func oidString(oid []byte) string {
 
 // A data structure produced by parsing DER-encoded bytes with UnmarshalDER().
 type Unmarshalled interface {
-  // The ASN.1 tag of the 1st entity in the DER bytes. At this time, this
-  // is just the 1st byte of the tag without any processing. In the future
-  // support for multi-byte tags may be added.
+  // The ASN.1 tag of the 1st entity in the DER bytes. This
+  // is just the 1st byte of the tag without any processing. For tag numbers >= 31
+  // use RawTag() to get the complete tag.
   Tag() int
+  
+  // The complete DER encoding of the tag (i.e. including all bytes of a multi-byte tag).
+  // This is form 1) as described in the doc of the Rawtag type.
+  // Empty for the UnmarshalledConstructed returned by UnmarshalDER() and friends.
+  RawTag() Rawtag
+  
+  // The tag number (without class and constructed bits) decoded from RawTag().
+  // -1 for the UnmarshalledConstructed returned by UnmarshalDER() and friends.
+  TagNumber() int
 }
 
 // There are 2 types of Rawtag:
@@ -454,12 +466,41 @@ type Unmarshalled interface {
 // 2) The byte(s) of one or more DER encoded ASN.1 tags with a 0-byte after each tag.
 //    The 0 byte is present even if there is only one tag. This means that form 2) and form 1)
 //    of Rawtag can be clearly distinguished.
+//    Note that the last byte of a multi-byte tag may be 0, too (e.g. [128]), so the
+//    tags have to be separated with tagLength() or splitTags() instead of looking for 0 bytes.
 type Rawtag string
 
+// Returns true iff r is a form 2) Rawtag.
+func (r Rawtag) isForm2() bool { return len(r) > tagLength([]byte(r)) }
+
+// Returns the number of bytes of the DER encoded tag at the start of tags.
+func tagLength(tags []byte) int {
+  i := 1
+  if len(tags) > 0 && tags[0] & 31 == 31 { // multi-byte tag
+    for i < len(tags) && tags[i] & 128 != 0 { i++ }
+    i++
+  }
+  if i > len(tags) { i = len(tags) }
+  return i
+}
+
+// Splits tags, which contains DER encoded tags with a 0-byte after each tag
+// (i.e. form 2) Rawtag or Tree.tags), into the individual tags without the 0-bytes.
+func splitTags(tags []byte) []Rawtag {
+  result := []Rawtag{}
+  for i := 0; i < len(tags); {
+    n := tagLength(tags[i:])
+    result = append(result, Rawtag(tags[i:i+n]))
+    i += n+1
+  }
+  return result
+}
+
 // Subtype of Unmarshalled that is produced by UnmarshalDER() when applied to a
 // CONSTRUCTED DER encoding.
 type UnmarshalledConstructed struct {
   _Tag int
+  rawtag Rawtag
   
   // This map contains 1 or 2 mappings entries for each element in the constructed sequence.
   // One entry, that always exists, has as its key the concatenation of the tags of
@@ -473,43 +514,146 @@ type UnmarshalledConstructed struct {
 }
 
 func (u *UnmarshalledConstructed) Tag() int { return u._Tag }
+func (u *UnmarshalledConstructed) RawTag() Rawtag { return u.rawtag }
+func (u *UnmarshalledConstructed) TagNumber() int { return u.rawtag.number() }
+
+// Returns the element of u that comes first in the encoding u has been unmarshalled from
+// (nil if u is empty) and the number of elements of u. Use this instead of ranging over
+// u.Data, whose iteration order is random.
+func (u *UnmarshalledConstructed) First() (Unmarshalled, int) {
+  var first Unmarshalled
+  count := 0
+  for key, ele := range u.Data {
+    if key.isForm2() {
+      count++
+      if tagLength([]byte(key)) == len(key)-1 { // just 1 tag => 1st element
+        first = ele
+      }
+    }
+  }
+  return first, count
+}
 
 // Subtype of Unmarshalled that is produced by UnmarshalDER() when applied to a
 // PRIMITIVE DER encoding.
 type UnmarshalledPrimitive struct {
   _Tag int
+  rawtag Rawtag
   
   // The raw content bytes of the primitive encoding without the tag and length bytes.
   Data []byte
 }
 
 func (u *UnmarshalledPrimitive) Tag() int { return u._Tag }
+func (u *UnmarshalledPrimitive) RawTag() Rawtag { return u.rawtag }
+func (u *UnmarshalledPrimitive) TagNumber() int { return u.rawtag.number() }
+
+// Returns the tag number of a form 1) Rawtag or -1 if r is empty.
+func (r Rawtag) number() int {
+  if len(r) == 0 {
+    return -1
+  }
+  if r[0] & 31 != 31 {
+    return int(r[0] & 31)
+  }
+  num := 0
+  for i := 1; i < len(r); i++ {
+    num = (num << 7) + int(r[i] & 127)
+  }
+  return num
+}
 
 // Parses the bytes in der[idx:] which have to be DER-encoded ASN.1 data structures
 // and returns the resulting tree. If there is any problem parsing the data, nil is returned.
+// Use UnmarshalDERWithError() to find out what the problem is.
 func UnmarshalDER(der []byte, idx int) *UnmarshalledConstructed {
+  u, _, _ := unmarshal(der, idx, false, false, nil)
+  return u
+}
+
+// Like UnmarshalDER() but also accepts the BER encodings not permitted in DER, i.e.
+// indefinite lengths and constructed encodings of strings. The result is normalised to
+// the same tree UnmarshalDER() returns for the DER encoding of the same data, i.e.
+// constructed strings become a single UnmarshalledPrimitive with the concatenated contents.
+func UnmarshalBER(ber []byte, idx int) *UnmarshalledConstructed {
+  u, _, _ := unmarshal(ber, idx, true, false, nil)
+  return u
+}
+
+// Like UnmarshalDER() but in case of a problem returns an *UnmarshalError that
+// describes it.
+func UnmarshalDERWithError(der []byte, idx int) (*UnmarshalledConstructed, error) {
+  u, _, err := unmarshal(der, idx, false, false, nil)
+  return u, err
+}
+
+// Like UnmarshalBER() but in case of a problem returns an *UnmarshalError that
+// describes it.
+func UnmarshalBERWithError(ber []byte, idx int) (*UnmarshalledConstructed, error) {
+  u, _, err := unmarshal(ber, idx, true, false, nil)
+  return u, err
+}
+
+// The error returned by UnmarshalDERWithError() and UnmarshalBERWithError().
+type UnmarshalError struct {
+  // Index of the byte in the input data at which the problem was detected.
+  Offset int
+  
+  // The tags (as hex bytes) of the encodings that contain the problem, from the outermost
+  // to the innermost. Empty if the problem was detected before a tag was read.
+  Path []string
+  
+  // Description of the problem.
+  Reason string
+}
+
+func (e *UnmarshalError) Error() string {
+  if len(e.Path) == 0 {
+    return fmt.Sprintf("Offset %v: %v", e.Offset, e.Reason)
+  }
+  return fmt.Sprintf("Offset %v (tag path %v): %v", e.Offset, strings.Join(e.Path, "/"), e.Reason)
+}
+
+// Implements the Unmarshal...() functions. ber == true means BER is accepted. If eoc == true, the
+// data is the contents of an indefinite length encoding and parsing stops after
+// the end of contents marker. path are the tags of the enclosing encodings (see UnmarshalError).
+// The 2nd return value is the index after the last byte parsed.
+func unmarshal(der []byte, idx int, ber bool, eoc bool, path []string) (*UnmarshalledConstructed, int, error) {
   seq := map[Rawtag]Unmarshalled{}
   
   conflict := map[Rawtag]bool{}
   
+  fail := func(offset int, reason string) (*UnmarshalledConstructed, int, error) {
+    return nil, offset, &UnmarshalError{Offset:offset, Path:path, Reason:reason}
+  }
+  
   // Each child is added to seq with 2 keys:
-  // 1) the tag of the child converted to string; this cannot contain any 0 bytes
+  // 1) the tag of the child converted to string
   // 2) all tags of preceding children concatenated with the child's tag, with a 0 byte after each tag
   // In case 2 siblings have the same tag, the entry 1) above is removed from the map
   preceding_tags := []byte{}
   
   for idx < len(der) {
+    start := idx
+    path := append(path[0:len(path):len(path)], fmt.Sprintf("%02X", der[idx]))
     tag := []byte{der[idx]}
     constructed := (tag[0] & 32) != 0
     if tag[0] & 31 == 31 {
       for {
         idx++
-        if idx == len(der) { // premature end of data
-          return nil 
+        if idx == len(der) {
+          return fail(idx, "Premature end of data within tag")
         }
         tag = append(tag, der[idx])
+        if len(tag) > 5 { // arbitrary cutoff to prevent overflow of the tag number
+          return nil, idx, &UnmarshalError{Offset:idx, Path:path, Reason:"Tag number too large"}
+        }
         if der[idx] & 128 == 0 { break }
       }
+      path[len(path)-1] = fmt.Sprintf("%X", tag)
+    }
+    fail := func(offset int, reason string) (*UnmarshalledConstructed, int, error) {
+      return nil, offset, &UnmarshalError{Offset:offset, Path:path, Reason:reason}
     }
     
     if Debug {
@@ -517,8 +661,8 @@ func UnmarshalDER(der []byte, idx int) *UnmarshalledConstructed {
     }
     
     idx++
-    if idx == len(der) { // premature end of data
-      return nil
+    if idx == len(der) {
+      return fail(idx, "Premature end of data before length")
     }
     
     length := int(der[idx])
@@ -526,19 +670,18 @@ func UnmarshalDER(der []byte, idx int) *UnmarshalledConstructed {
       length &= 127
       if length == 0 { // indefinite length
         length = -1
-        if !constructed { // error, indefinite length is only permitted with constructed
-          return nil
+        if !constructed {
+          return fail(idx, "Indefinite length is only permitted with constructed encoding")
         }
       } else { // definite multi-byte length
-        if length > 3 { // reject data structures larger than 16MB (or incorrectly encoded length)
-          return nil
-        }
-        
         l := 0
         for length > 0 {
           idx++
-          if idx == len(der) { // premature end of data
-            return nil
+          if idx == len(der) {
+            return fail(idx, "Premature end of data within length")
+          }
+          if l > len(der) { // cannot fit anyway; stop before l overflows
+            return fail(idx, "Length exceeds the available data")
           }
           l = (l << 8) + int(der[idx])
           length--
@@ -552,35 +695,58 @@ func UnmarshalDER(der []byte, idx int) *UnmarshalledConstructed {
     }
     
     if tag[0] == 0 && length == 0 { // end of contents marker
-      return nil // indefinite length (and hence end of contents markers) not permitted in DER
+      if ber && eoc {
+        return &UnmarshalledConstructed{_Tag:-1, Data:seq}, idx+1, nil
+      }
+      if ber {
+        return fail(start, "End of contents marker outside of indefinite length encoding")
+      }
+      return fail(start, "End of contents marker not permitted in DER")
     }
     
-    preceding_tags = append(preceding_tags, tag...)
-    preceding_tags = append(preceding_tags, 0) // separator
-
     var contents Unmarshalled
     
     if constructed {
       idx++
+      var cont *UnmarshalledConstructed
+      var err error
       if length < 0 { // indefinite length
-        return nil // indefinite length not permitted in DER
-      } else if idx+length > len(der) { // length exceeds available data
-        return nil
-      } else {
-        cont := UnmarshalDER(der[0:idx+length], idx)
-        if cont == nil { // if an error occurred
-          return nil
+        if !ber {
+          return fail(idx-1, "Indefinite length not permitted in DER")
         }
+        cont, idx, err = unmarshal(der, idx, ber, true, path)
+      } else if idx+length > len(der) {
+        return fail(idx-1, fmt.Sprintf("Length %v exceeds the %v bytes of available data", length, len(der)-idx))
+      } else {
+        cont, _, err = unmarshal(der[0:idx+length], idx, ber, false, path)
         idx += length
-        cont._Tag = int(tag[0])
-        contents = cont
+      }
+      if err != nil {
+        return nil, idx, err
+      }
+      cont._Tag = int(tag[0])
+      cont.rawtag = Rawtag(tag)
+      contents = cont
+      
+      if ber && len(tag) == 1 && berStringTypes[tag[0] & 31] && tag[0] & (128+64) == 0 { // constructed UNIVERSAL string
+        tag[0] &^= 32
+        contents = berString(cont, tag[0])
+        if contents == nil {
+          return fail(start, "Constructed string contains something other than segments of the same string type")
+        }
       }
     } else { // primitive
       idx++
-      contents = &UnmarshalledPrimitive{_Tag:int(tag[0]), Data:der[idx:idx+length]}
+      if idx+length > len(der) {
+        return fail(idx-1, fmt.Sprintf("Length %v exceeds the %v bytes of available data", length, len(der)-idx))
+      }
+      contents = &UnmarshalledPrimitive{_Tag:int(tag[0]), rawtag:Rawtag(tag), Data:der[idx:idx+length]}
       idx += length
     }
     
+    preceding_tags = append(preceding_tags, tag...)
+    preceding_tags = append(preceding_tags, 0) // separator
+    
     tagstr1 := Rawtag(tag)
     tagstr2 := Rawtag(preceding_tags)
     if Debug {
@@ -598,7 +764,52 @@ func UnmarshalDER(der []byte, idx int) *UnmarshalledConstructed {
     seq[tagstr2] = contents
   }
   
-  return &UnmarshalledConstructed{_Tag:-1, Data:seq}
+  if eoc {
+    return fail(idx, "Premature end of data before end of contents marker")
+  }
+  
+  return &UnmarshalledConstructed{_Tag:-1, Data:seq}, idx, nil
+}
+
+// The UNIVERSAL tag numbers of the string types that BER permits to be encoded in
+// constructed form (BIT STRING, OCTET STRING and the character string types).
+var berStringTypes = map[byte]bool{3:true, 4:true, 12:true, 18:true, 19:true, 20:true, 21:true, 22:true, 25:true, 26:true, 27:true, 28:true, 30:true}
+
+// Converts the constructed BER encoding cont of a string with the (primitive) tag
+// to the primitive encoding by concatenating the segments. Returns nil if
+// cont contains anything but segments of the same type.
+func berString(cont *UnmarshalledConstructed, tag byte) *UnmarshalledPrimitive {
+  // The form 2) keys (see UnmarshalledConstructed) of the segments are each one
+  // tag longer than the previous one, so sorting them by length gives the segment order.
+  keys := []string{}
+  for k := range cont.Data {
+    if k.isForm2() {
+      keys = append(keys, string(k))
+    }
+  }
+  sort.Slice(keys, func(i, j int) bool { return len(keys[i]) < len(keys[j]) })
+  
+  data := []byte{}
+  unused := byte(0)
+  for i, k := range keys {
+    segment, ok := cont.Data[Rawtag(k)].(*UnmarshalledPrimitive)
+    if !ok || segment._Tag != int(tag) {
+      return nil
+    }
+    if tag == 3 { // BIT STRING segments start with the number of unused bits; only the last may be non-0
+      if len(segment.Data) == 0 || (unused != 0 && i > 0) {
+        return nil
+      }
+      unused = segment.Data[0]
+      data = append(data, segment.Data[1:]...)
+    } else {
+      data = append(data, segment.Data...)
+    }
+  }
+  if tag == 3 {
+    data = append([]byte{unused}, data...)
+  }
+  return &UnmarshalledPrimitive{_Tag:int(tag), rawtag:Rawtag([]byte{tag}), Data:data}
 }
 
 /*
@@ -606,33 +817,48 @@ func UnmarshalDER(der []byte, idx int) *UnmarshalledConstructed {
   which may optionally be base64 encoded and may be preceded
   by "garbage". The returned data will always be DER bytes
   without preceding garbage and NOT base64 encoded.
-  The SEQUENCE will only be recognized as valid if
-  it does not contain APPLICATION or PRIVATE tags or
-  tags >= 31.
+  BER indefinite length encodings are accepted, too, and returned
+  unchanged. Use UnmarshalBER() to parse them.
   This function takes care not to read more bytes than
   necessary which allows the function to be called
   multiple times on a stream of concatenated SEQUENCEs.
 */
 func ReadNextSEQUENCE(r io.Reader) ([]byte, error) {
+  return ReadNextSEQUENCELimited(r, 0)
+}
+
+/*
+  Like ReadNextSEQUENCE() but SEQUENCEs whose encoding (without
+  base64 encoding) is larger than maxsize bytes are treated as garbage.
+  maxsize <= 0 means no limit.
+*/
+func ReadNextSEQUENCELimited(r io.Reader, maxsize int) ([]byte, error) {
   b := []byte{0}
   var err error
   var n int
   space := true
   var eaters deque.Deque
+  // If true, the last eater has completed while an eater that started earlier
+  // is still reading. Completed eaters keep returning 1 without eating.
+  completed := false
   for {
     n, err = r.Read(b)
+    if err == nil && n == 0 {
+      err = io.EOF
+    }
     if err != nil {
+      if completed { // the earlier eaters will never complete
+        return eaters.At(eaters.Count()-1).(eater).Data(), nil
+      }
       return nil, err
     }
-    if n == 0 { 
-      return nil, io.EOF
-    }
-    if b[0] == 0x30 { // SEQUENCE
-      eaters.Push(newRawEater())
+    // eaters that start after a completed one are never used
+    if b[0] == 0x30 && !completed { // SEQUENCE
+      eaters.Push(newRawEater(maxsize))
     } 
     if b[0] > ' ' {
-      if space {
-        eaters.Push(newBase64Eater())
+      if space && !completed {
+        eaters.Push(newBase64Eater(maxsize))
       }
       space = false
     } else {
@@ -647,7 +873,17 @@ func ReadNextSEQUENCE(r io.Reader) ([]byte, error) {
         case 0:  // ok, need more data
           i++
         case 1:  // done
-          return eaters.At(i).(eater).Data(), nil
+          if i == 0 {
+            return eaters.At(i).(eater).Data(), nil
+          }
+          // An eater that started earlier is still reading, so the completed eater
+          // has probably read a nested SEQUENCE (or garbage within the data).
+          // Its result is only used if all earlier eaters fail.
+          for eaters.Count() > i+1 {
+            eaters.RemoveAt(eaters.Count()-1)
+          }
+          completed = true
+          i++
       }
     }
   }
@@ -662,10 +898,28 @@ func ReadNextSEQUENCE(r io.Reader) ([]byte, error) {
   multiple times on a stream of concatenated keys.
 */
 func ReadNextKey(in io.Reader) (crypto.Signer, error) {
+  return ReadNextEncryptedKey(in, nil)
+}
+
+/*
+  Like ReadNextKey() but if the key is encrypted (PKCS#8 EncryptedPrivateKeyInfo)
+  it is decrypted with passphrase. Unencrypted keys are accepted, too.
+  If passphrase is nil, encrypted keys cause an error.
+*/
+func ReadNextEncryptedKey(in io.Reader, passphrase []byte) (crypto.Signer, error) {
   data, err := ReadNextSEQUENCE(in)
   if err != nil {
     return nil, err
   }
+  if IsEncryptedPKCS8(data) {
+    if passphrase == nil {
+      return nil, fmt.Errorf("Key is encrypted but no passphrase has been provided")
+    }
+    data, err = DecryptPKCS8(data, passphrase)
+    if err != nil {
+      return nil, err
+    }
+  }
   key1, err1 := x509.ParseECPrivateKey(data)
   key2, err2 := x509.ParsePKCS1PrivateKey(data)
   key3, err3 := x509.ParsePKCS8PrivateKey(data)
@@ -681,6 +935,7 @@ func ReadNextKey(in io.Reader) (crypto.Signer, error) {
   switch key := key3.(type){
     case *rsa.PrivateKey: return key, nil
     case *ecdsa.PrivateKey: return key, nil
+    case ed25519.PrivateKey: return key, nil
   }
   return nil, fmt.Errorf("Unknown key type in PKCS8 container")
 }
@@ -720,26 +975,46 @@ func (e *base64Eater) Data() []byte {
   return e.daisy.Data()
 }
 
-func newBase64Eater() eater {
-  return &base64Eater{daisy:newRawEater()}
+func newBase64Eater(maxsize int) eater {
+  return &base64Eater{daisy:newRawEater(maxsize)}
 }
 
 type rawEater struct {
   status int
   data []byte
+  maxsize int // maximum size of the SEQUENCE (<= 0 means unlimited)
   state int
   constructed bool
   length []int
   length_count int
   length_buffer int
+  tag_count int
 }
 
 func (e *rawEater) Data() []byte { return e.data }
 
-func newRawEater() eater { return &rawEater{} }
+func newRawEater(maxsize int) eater { return &rawEater{maxsize:maxsize} }
+
+// Lengths larger than this are rejected to prevent int overflow.
+const maxEaterLength = 1 << 48
+
+// Returns the number of bytes left in the innermost definite length encoding
+// that is being read or -1 if there is none.
+func (e *rawEater) available() int {
+  for i := len(e.length)-1; i >= 0; i-- {
+    if e.length[i] >= 0 {
+      return e.length[i]
+    }
+  }
+  return -1
+}
 
 func (e *rawEater) Eat(b byte) int {
   if e.status != 0 { return e.status }
+  if e.maxsize > 0 && len(e.data) >= e.maxsize {
+    e.status = -1
+    return e.status
+  }
   if e.state == 0 { // waiting for the initial 0x30
     if b != 0x30 {
       e.status = -1 // error
@@ -748,8 +1023,15 @@ func (e *rawEater) Eat(b byte) int {
       e.constructed = true
     }
   } else if e.state == 1 { // tag has been read, now read 1st length byte
-    if b <= 127 { // short form length
-      if len(e.length) > 0 && int(b) > e.length[len(e.length)-1]-1 { // -1 because the length byte we just parsed has not been subtracted yet
+    if b == 128 { // indefinite length (BER)
+      if !e.constructed {
+        e.status = -1 // error, indefinite length is only permitted with constructed
+      } else {
+        e.length = append(e.length, -1) // -1 is never decremented; popped by the end of contents marker
+        e.state = 3
+      }
+    } else if b <= 127 { // short form length
+      if e.available() >= 0 && int(b) > e.available()-1 { // -1 because the length byte we just parsed has not been subtracted yet
         // if new object doesn't fit into surrounding structure
         e.status = -1 // error
       } else {
@@ -762,8 +1044,7 @@ func (e *rawEater) Eat(b byte) int {
       }
     } else {
       e.length_count = int(b) & 127
-      if e.length_count == 0 || e.length_count > 2 {
-        // illegal length: 0 or more than 64K
+      if e.length_count == 127 { // reserved for future extensions by X.690
         e.status = -1
       }
       e.length_buffer = 0
@@ -773,8 +1054,10 @@ func (e *rawEater) Eat(b byte) int {
     e.length_buffer <<= 8
     e.length_buffer  += int(b)
     e.length_count--
-    if e.length_count == 0 {
-      if len(e.length) > 0 && e.length_buffer > e.length[len(e.length)-1]-1 { // -1 because the length byte we just parsed has not been subtracted yet
+    if e.length_buffer > maxEaterLength || (e.maxsize > 0 && e.length_buffer > e.maxsize) {
+      e.status = -1
+    } else if e.length_count == 0 {
+      if e.available() >= 0 && e.length_buffer > e.available()-1 { // -1 because the length byte we just parsed has not been subtracted yet
         // if new object doesn't fit into surrounding structure
         e.status = -1 // error
       } else {
@@ -786,13 +1069,29 @@ func (e *rawEater) Eat(b byte) int {
         }
       }
     }
-  } else if e.state == 3 { // inside constructed, expecting tag byte
-    if (b & 64) != 0 ||  // the structures we're interested in do not contain APPLICATION or PRIVATE tags
-       (b & 31) == 31 {  // the structures we're interested in do not have tags >= 31
+  } else if e.state == 3 && b == 0 && len(e.length) > 0 && e.length[len(e.length)-1] < 0 {
+    e.state = 5 // 1st byte of end of contents marker of indefinite length encoding
+  } else if e.state == 5 { // 2nd byte of end of contents marker
+    if b != 0 {
       e.status = -1
     }
+    e.length = e.length[0:len(e.length)-1]
+    e.state = 3
+  } else if e.state == 3 { // inside constructed, expecting tag byte
     e.constructed = (b & 32 != 0)
-    e.state = 1
+    if (b & 31) == 31 { // multi-byte tag
+      e.tag_count = 0
+      e.state = 6
+    } else {
+      e.state = 1
+    }
+  } else if e.state == 6 { // reading subsequent bytes of multi-byte tag
+    e.tag_count++
+    if e.tag_count > 4 { // arbitrary cutoff, same as UnmarshalDER()
+      e.status = -1
+    } else if b & 128 == 0 {
+      e.state = 1
+    }
   } else if e.state == 4 { // inside primitive, expecting data byte
     // nothing to do
   }
@@ -800,16 +1099,27 @@ func (e *rawEater) Eat(b byte) int {
   e.data = append(e.data, b)
   
   for i := range e.length {
-    e.length[i]--
+    if e.length[i] > 0 {
+      e.length[i]--
+    }
   }
   
   for len(e.length) > 0 && e.length[len(e.length)-1] == 0 {
+    if e.state != 3 && e.state != 4 { // definite length ended within a tag or length
+      e.status = -1
+    }
     e.length = e.length[0:len(e.length)-1]
     e.constructed = true
     e.state = 3
   }
   
-  if len(e.length) == 0 {
+  for i := range e.length {
+    if e.length[i] == 0 { // definite length ended within an unterminated indefinite length encoding
+      e.status = -1
+    }
+  }
+  
+  if len(e.length) == 0 && e.status == 0 {
     switch e.state {
       case 3: e.status = 1
       case 1,2: {} // still waiting for length of outermost SEQUENCE
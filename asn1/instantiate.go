@@ -23,8 +23,10 @@ import (
          "fmt"
          "math"
          "regexp"
+         "sort"
          "strings"
          "strconv"
+         "time"
          "math/big"
          "unicode/utf8"
        )
@@ -41,7 +43,7 @@ func (d *Definitions) Value(valuename string) (*Instance, error) {
     return nil, fmt.Errorf("Value %v is undefined", valuename)
   }
   // children are not handled because compound value definitions are not supported, so no value can have children.
-  return &Instance{nodetype:instanceNode, tags:v.tags, source_tag:v.source_tag, implicit:v.implicit, name:valuename, typename:v.typename, basictype:v.basictype, value:v.value, namedints:v.namedints, src:v.src, pos:v.pos}, nil
+  return &Instance{nodetype:instanceNode, tags:v.tags, source_tag:v.source_tag, implicit:v.implicit, name:valuename, typename:v.typename, basictype:v.basictype, value:v.value, namedints:v.namedints, stringTag:v.stringTag, src:v.src, pos:v.pos}, nil
 }
 
 // Creates an instance of the type called typename whose definition has to be
@@ -51,16 +53,29 @@ func (d *Definitions) Value(valuename string) (*Instance, error) {
 // 
 // All types can be instantiated from a compatible *Instance or Unmarshalled.
 //
-// SEQUENCE/SET/CHOICE => map[string]interface{} where the keys match the ASN.1 field names
+// SEQUENCE/SET/CHOICE => map[string]interface{} where the keys match the ASN.1 field names.
+//                        If the type is extensible (i.e. has an extension marker "..."),
+//                        extension additions may be missing and the keys "...0", "...1",...
+//                        are instantiated as ANY and appended in that order (these are the
+//                        unknown extension additions found when decoding DER data).
+// CHOICE => additionally an *Instance whose tag matches one of the alternatives, or
+//           a time.Time if the CHOICE has UTCTime and/or GeneralizedTime alternatives.
+//           In the latter case the alternative is selected according to TimeTagFor().
 // SEQUENCE_OF/SET_OF => []interface{}
 // OCTET_STRING => string or []byte or []int (if all elements are 0<=i<=255)
+// UTCTime/GeneralizedTime => like OCTET_STRING, but a string must either conform to DER or
+//                            be accepted by ParseTime(). Also accepts time.Time.
+// NumericString/PrintableString/IA5String/VisibleString => like OCTET_STRING, but it is an error if the
+//                            data contains a character not from the type's alphabet. This is checked
+//                            for Unmarshalled data, too.
 // BOOLEAN => bool or string that compares (case-insensitive) to "false" or "true"
 // NULL => nil or string that compares (case-insensitive) to "null"
 // INTEGER => int, float64, *big.Int or string that either parses as an integer or compares (CASE-SENSITIVE) to
 //            one of the named numbers for from the ASN.1 source for the respective context.
 //            A float64 must not have a fractional part.
 // ENUMERATED => like INTEGER above, but it is an error if the number does not match one of
-//               the named numbers from the ASN.1 source for the respective context.
+//               the named numbers from the ASN.1 source for the respective context, unless
+//               the ENUMERATED is extensible (i.e. has an extension marker "...").
 // BIT_STRING => Option 1: a string of the form "0b..." where "..." is composed of "0" and "1"
 //                         characters. Spaces are permitted anywhere in the string.
 //               Option 2: (string) list of words from the bit names from the ASN.1 source where
@@ -84,10 +99,13 @@ func (d *Definitions) Value(valuename string) (*Instance, error) {
 //        []int (encoded as OBJECT IDENTIFIER),
 //        []byte (encoded as OCTET STRING),
 //        []interface{} (encoded as SEQUENCE OF ANY),
+//        Unmarshalled SEQUENCE or SET (encoded as SEQUENCE OF ANY or SET OF ANY),
+//        Unmarshalled with any other unknown tag (encoded with the original tag and contents),
 //        string (encoded as UTF8String),
 //        []bool (encoded as BIT STRING)
 //        float64 (encoded as INTEGER if an integral number)
 //        nil (encoded as NULL)
+//        time.Time (encoded as UTCTime or GeneralizedTime according to TimeTagFor())
 func (d *Definitions) Instantiate(typename string, data interface{}) (*Instance, error) {
   t, ok := d.typedefs[typename]
   if !ok {
@@ -95,7 +113,7 @@ func (d *Definitions) Instantiate(typename string, data interface{}) (*Instance,
   }
 
   // Fill in typename, because t does not have typename set (see comment in tree.go)
-  inst := &Tree{nodetype:t.nodetype, tags:t.tags, source_tag:t.source_tag, implicit:t.implicit, typename:t.name, basictype:t.basictype, value:t.value, children:t.children, namedints:t.namedints, src:t.src, pos:t.pos}
+  inst := &Tree{nodetype:t.nodetype, tags:t.tags, source_tag:t.source_tag, implicit:t.implicit, typename:t.name, basictype:t.basictype, value:t.value, children:t.children, namedints:t.namedints, stringTag:t.stringTag, extensionMarkers:t.extensionMarkers, size:t.size, valueRange:t.valueRange, src:t.src, pos:t.pos}
   return inst.instantiate(data,&pathNode{})
 }
 
@@ -115,8 +133,21 @@ func (p *pathNode) str() string {
   return p.parent.str()+p.name
 }
 
+// Instantiates t with data and checks the result against the SIZE and value range
+// constraints and the permitted alphabet of t. They are not checked if data is Unmarshalled,
+// because real-world DER data (e.g. certificates with overlong names or wildcard
+// PrintableStrings) often violates them and must still be decodable.
 func (t *Tree) instantiate(data interface{}, p *pathNode) (*Instance, error) {
-  inst := &Instance{nodetype:instanceNode, tags:t.tags, source_tag:t.source_tag, implicit:t.implicit, name:t.name, typename:t.typename, basictype:t.basictype, namedints:t.namedints, src:t.src, pos:t.pos}
+  inst, err := t.instantiateUnchecked(data, p)
+  if err != nil { return nil, err }
+  if _, unmarshalled := data.(Unmarshalled); !unmarshalled {
+    if err = t.checkConstraints(inst, p); err != nil { return nil, err }
+  }
+  return inst, nil
+}
+
+func (t *Tree) instantiateUnchecked(data interface{}, p *pathNode) (*Instance, error) {
+  inst := &Instance{nodetype:instanceNode, tags:t.tags, source_tag:t.source_tag, implicit:t.implicit, name:t.name, typename:t.typename, basictype:t.basictype, namedints:t.namedints, stringTag:t.stringTag, extensionMarkers:t.extensionMarkers, src:t.src, pos:t.pos}
   
   var inst2 *Tree
   switch d := data.(type) {
@@ -124,15 +155,13 @@ func (t *Tree) instantiate(data interface{}, p *pathNode) (*Instance, error) {
         var d2 Unmarshalled
         if t.basictype == ANY || t.basictype == CHOICE {
           // strip away all constructed shells (i.e. one per tag)
-          for _, b := range t.tags {
-            if b == 0 {
-              dx, _ := d2.(*UnmarshalledConstructed)
-              if dx == nil { dx = d }
-              // take the first element
-              for _, ele := range dx.Data {
-                d2 = ele
-                break
-              }
+          for range splitTags(t.tags) {
+            dx, _ := d2.(*UnmarshalledConstructed)
+            if dx == nil { dx = d }
+            // take the first element
+            for _, ele := range dx.Data {
+              d2 = ele
+              break
             }
           }
         } else { // if the type is something other than ANY or CHOICE
@@ -157,7 +186,7 @@ func (t *Tree) instantiate(data interface{}, p *pathNode) (*Instance, error) {
                 }
   }
   if inst2 != nil {
-    if inst.basictype != ANY && inst2.basictype != inst.basictype {
+    if inst.basictype != ANY && inst.basictype != CHOICE && inst2.basictype != inst.basictype {
       return nil, fmt.Errorf("%vAttempt to instantiate type %v from Instance of type %v", p, BasicTypeName[inst.basictype], BasicTypeName[inst2.basictype])
     }
   }
@@ -170,12 +199,40 @@ func (t *Tree) instantiate(data interface{}, p *pathNode) (*Instance, error) {
                  // the proper name and call instantiate() recursively. If we don't find a proper
                  // child we just proceed the same as when the data is not Unmarshalled. The
                  // Unmarshalled will then cause an error somewhere down the line.
-                 if d, ok := data.(Unmarshalled); ok {
-                   for _, c := range t.children {
-                     if len(c.tags) > 0 && c.tags[0] == byte(d.Tag()) {
-                       return t.instantiate(map[string]interface{}{c.name:data}, p)
+                 // The same is done for an *Instance of a different type and for a time.Time
+                 // (which selects UTCTime or GeneralizedTime according to TimeTagFor()).
+                 switch d := data.(type) {
+                   case Unmarshalled:
+                     untagged := false
+                     for _, c := range t.children {
+                       if len(c.tags) > 0 && firstTag(c.tags) == d.RawTag() {
+                         return t.instantiate(map[string]interface{}{c.name:data}, p)
+                       }
+                       untagged = untagged || len(c.tags) == 0
+                     }
+                     // If no alternative matches (and none could match via a tag of its own
+                     // nested alternatives), the data is an unknown extension addition.
+                     if t.extensionMarkers > 0 && !untagged {
+                       return t.instantiate(map[string]interface{}{"...0":data}, p)
+                     }
+                   case *Instance:
+                     if d.typename != inst.typename {
+                       for _, c := range t.children {
+                         if len(c.tags) > 0 && len(d.tags) > 0 && firstTag(c.tags) == firstTag(d.tags) {
+                           return t.instantiate(map[string]interface{}{c.name:data}, p)
+                         }
+                       }
+                     }
+                   case time.Time:
+                     var alternative *Tree
+                     for _, c := range t.children {
+                       if tag := timeTag(c); tag != 0 && (alternative == nil || tag == TimeTagFor(d)) {
+                         alternative = c
+                       }
+                     }
+                     if alternative != nil {
+                       return t.instantiate(map[string]interface{}{alternative.name:data}, p)
                      }
-                   }
                  }
                  inst2, err := instantiateSEQUENCE(-1, inst, t.children, data, p)
                  if err == nil {
@@ -192,6 +249,8 @@ func (t *Tree) instantiate(data interface{}, p *pathNode) (*Instance, error) {
     case INTEGER: return instantiateINTEGER(inst, data, p)
     case ENUMERATED: inst2, err := instantiateINTEGER(inst, data, p)
                      if err != nil { return inst2, err }
+                     // An extensible ENUMERATED accepts numbers from later versions of the definition.
+                     if t.extensionMarkers > 0 { return inst2, nil }
                      switch val := inst2.value.(type) {
                        case int:
                          for _, i := range inst2.namedints {
@@ -241,15 +300,39 @@ func instantiateANY(inst *Instance, data interface{}, p *pathNode) (*Instance, e
                           inst.basictype = ENUMERATED
                           inst.tags = append(inst.tags, byte(BasicTypeTag[inst.basictype]), 0)
                           return instantiateINTEGER(inst, data, p)
-                  case 4,12,18,19,20,21,22,25,26,27,28,29,30: // *String
+                  case 4,12,18,19,20,21,22,23,24,25,26,27,28,29,30: // *String, *Time
                           inst.basictype = OCTET_STRING
+                          inst.stringTag = data.Tag()
                           if data.Tag() != 4 && data.Tag() != 29 {
                             inst.typename = UniversalTagName[data.Tag()]
                           }
                           inst.tags = append(inst.tags, byte(data.Tag()), 0)
                           return instantiateOCTET_STRING(inst, data, p)
+                  case 16+32, 17+32: // SEQUENCE, SET (always constructed)
+                          // The actual type is unknown, so we treat it as SEQUENCE OF ANY or SET OF ANY
+                          if data.Tag() == 16+32 {
+                            inst.basictype = SEQUENCE_OF
+                          } else {
+                            inst.basictype = SET_OF
+                          }
+                          inst.tags = append(inst.tags, byte(data.Tag()), 0)
+                          return instantiateSEQUENCE_OF(BasicTypeTag[inst.basictype], inst, anyElement(inst), data, p)
                   default: 
-                          return nil, fmt.Errorf("%vUnsupported unmarshalled type (tag %x) to instantiate ANY with", p, data.Tag())
+                          // The actual type is unknown (e.g. an unknown extension addition), so we keep
+                          // the original tag and contents (see the doc of Tree.isRaw).
+                          inst.isRaw = true
+                          inst.tags = append(inst.tags, data.RawTag()...)
+                          inst.tags = append(inst.tags, 0)
+                          switch data := data.(type) {
+                            case *UnmarshalledPrimitive:
+                              inst.basictype = OCTET_STRING
+                              inst.value = data.Data
+                              return inst, nil
+                            case *UnmarshalledConstructed:
+                              inst.basictype = SEQUENCE_OF
+                              return instantiateSEQUENCE_OF(-1, inst, anyElement(inst), data, p)
+                          }
+                          return nil, fmt.Errorf("%vUnsupported unmarshalled type (tag %x) to instantiate ANY with", p, []byte(data.RawTag()))
                 }
     case *Instance:
                 inst.tags = append(inst.tags, data.tags...)
@@ -258,6 +341,7 @@ func instantiateANY(inst *Instance, data interface{}, p *pathNode) (*Instance, e
                 inst.value = data.value
                 inst.children = data.children
                 inst.namedints = data.namedints
+                inst.stringTag = data.stringTag
                 inst.src = data.src
                 inst.pos = data.pos
                 return inst, nil
@@ -274,23 +358,37 @@ func instantiateANY(inst *Instance, data interface{}, p *pathNode) (*Instance, e
                inst.tags = append(inst.tags, byte(BasicTypeTag[inst.basictype]), 0)
                return instantiateOBJECT_IDENTIFIER(inst, data, p)
     case []byte: inst.basictype = OCTET_STRING
+               inst.stringTag = BasicTypeTag[OCTET_STRING]
                inst.tags = append(inst.tags, byte(BasicTypeTag[inst.basictype]), 0)
                return instantiateOCTET_STRING(inst, data, p)
     case string: inst.basictype = OCTET_STRING
+                 inst.stringTag = 12 // UTF8String
                  inst.typename = "UTF8String"
                  inst.tags = append(inst.tags, 12, 0) // UTF8String
                  return instantiateOCTET_STRING(inst, data, p)
+    case time.Time: inst.basictype = OCTET_STRING
+               tag := TimeTagFor(data)
+               inst.stringTag = tag
+               inst.typename = UniversalTagName[tag]
+               inst.tags = append(inst.tags, byte(tag), 0)
+               return instantiateOCTET_STRING(inst, data, p)
     case []bool: inst.basictype = BIT_STRING
                  inst.tags = append(inst.tags, byte(BasicTypeTag[inst.basictype]), 0)
                  return instantiateBIT_STRING(inst, data, p)
     case []interface{}: 
                  inst.basictype = SEQUENCE_OF
                  inst.tags = append(inst.tags, byte(BasicTypeTag[inst.basictype])|32, 0) // 32 => constructed
-                 return instantiateSEQUENCE_OF(16, inst, &Tree{nodetype:instanceNode, tags:[]byte{}, source_tag:-1, implicit:false, basictype:ANY, src:inst.src, pos:inst.pos} , data, p)
+                 return instantiateSEQUENCE_OF(16, inst, anyElement(inst), data, p)
     default: return nil, instantiateTypeError(p, "ANY", data)
   }
 }
 
+// Returns an untagged ANY type for the elements of a SEQUENCE OF ANY or SET OF ANY
+// (or the unknown extension additions of a SEQUENCE, SET or CHOICE) within inst.
+func anyElement(inst *Instance) *Tree {
+  return &Tree{nodetype:instanceNode, tags:[]byte{}, source_tag:-1, implicit:false, basictype:ANY, src:inst.src, pos:inst.pos}
+}
+
 func instantiateBIT_STRING(inst *Instance, data interface{}, p *pathNode) (*Instance, error) {
   switch data := data.(type) {
     case *Instance: inst.value = data.value
@@ -477,7 +575,19 @@ func instantiateNULL(inst *Instance, data interface{}, p *pathNode) (*Instance,
 func instantiateOCTET_STRING(inst *Instance, data interface{}, p *pathNode) (*Instance, error) {
   switch data := data.(type) {
     case *Instance: inst.value = data.value
-    case string: inst.value = []byte(data)
+    case string: if tag := timeTag((*Tree)(inst)); tag != 0 {
+                   v, err := encodeTime(tag, data)
+                   if err != nil { return nil, fmt.Errorf("%v%v", p, err) }
+                   inst.value = v
+                 } else {
+                   inst.value = []byte(data)
+                 }
+    case time.Time:
+                tag := timeTag((*Tree)(inst))
+                if tag == 0 { return nil, instantiateTypeError(p, "OCTET STRING", data) }
+                v, err := encodeTime(tag, data)
+                if err != nil { return nil, fmt.Errorf("%v%v", p, err) }
+                inst.value = v
     case []byte: inst.value = data
     case []int: d := make([]byte, len(data))
                 for idx, i := range data {
@@ -489,7 +599,7 @@ func instantiateOCTET_STRING(inst *Instance, data interface{}, p *pathNode) (*In
                 inst.value = d
     
     case *UnmarshalledPrimitive: 
-                if inst.tags[len(inst.tags)-2] == 30 { // BMPString; -2 because the last byte is 0
+                if inst.stringTag == 30 { // BMPString
                    inst.value = decodeUTF16(data.Data)
                  } else {
                    inst.value = data.Data
@@ -517,17 +627,35 @@ func instantiateSEQUENCE(deftag int, inst *Instance, children []*Tree, data inte
           inst.children = append(inst.children, (*Tree)(child))
           child.isDefaultValue = c.optional && equalValues(c.value, child.value)
         } else {
-          if !c.optional { return nil, fmt.Errorf("%vMissing data for non-optional field %v", p, c.name) }
+          if !c.optional && !c.extension { return nil, fmt.Errorf("%vMissing data for non-optional field %v", p, c.name) }
           if c.value != nil {
-            child := &Instance{nodetype:instanceNode, tags:c.tags, source_tag:c.source_tag, implicit:c.implicit, name:c.name, typename:c.typename, basictype:c.basictype, value:c.value, namedints:c.namedints, src:c.src, pos:c.pos}
+            child := &Instance{nodetype:instanceNode, tags:c.tags, source_tag:c.source_tag, implicit:c.implicit, name:c.name, typename:c.typename, basictype:c.basictype, value:c.value, namedints:c.namedints, stringTag:c.stringTag, src:c.src, pos:c.pos}
             inst.children = append(inst.children, (*Tree)(child))
             child.isDefaultValue = equalValues(c.value, child.value)
           }
         }
       }
+      if inst.extensionMarkers > 0 {
+        // Unknown extension additions (see mapRawtagsToNames()) in their original order.
+        names := []string{}
+        for name := range data {
+          if strings.HasPrefix(name, "...") {
+            names = append(names, name)
+          }
+        }
+        sort.Slice(names, func(i, j int) bool {
+          return len(names[i]) < len(names[j]) || (len(names[i]) == len(names[j]) && names[i] < names[j])
+        })
+        for _, name := range names {
+          child, err := anyElement(inst).instantiate(data[name], &pathNode{parent:p, name:"/"+name})
+          if err != nil { return nil, err }
+          child.name = name
+          inst.children = append(inst.children, (*Tree)(child))
+        }
+      }
       return inst, nil
     case *UnmarshalledConstructed:
-      return instantiateSEQUENCE(deftag, inst, children, mapRawtagsToNames(children, data) ,p)
+      return instantiateSEQUENCE(deftag, inst, children, mapRawtagsToNames(children, data, inst.extensionMarkers > 0) ,p)
     default: 
       return nil, instantiateTypeError(p, "SEQUENCE/SET/CHOICE", data)
   }
@@ -553,7 +681,7 @@ func instantiateSEQUENCE_OF(deftag int, inst *Instance, eletype *Tree, data inte
       // and sort them by increasing key length
       keys := make([]Rawtag, 0, len(data.Data))
       for key := range data.Data {
-        if key[len(key)-1] == 0 {
+        if key.isForm2() {
           idx := len(keys)-1
           keys = append(keys, "")
           for idx >= 0 && len(key) < len(keys[idx]) {
@@ -575,6 +703,11 @@ func instantiateSEQUENCE_OF(deftag int, inst *Instance, eletype *Tree, data inte
   }
 }
 
+// Returns the 1st tag from tags (see Tree.tags) without the following 0 byte.
+func firstTag(tags []byte) Rawtag {
+  return Rawtag(tags[0:tagLength(tags)])
+}
+
 // In the *Tree, a single node may have multiple tags, but in the DER-encoding
 // this looks like multiple nested SEQUENCEs with just one member. When such
 // a DER encoding is decoded with UnmarshalDER(), the result is a structure
@@ -589,24 +722,19 @@ func instantiateSEQUENCE_OF(deftag int, inst *Instance, eletype *Tree, data inte
 //       t1) is that t1 is the key that maps to data.
 func stripTags(data *UnmarshalledConstructed, tags []byte) Unmarshalled {
   var result Unmarshalled
-  tagstart := 0
-  for tagstart < len(tags) && tags[tagstart] != 0 { tagstart++ }
-  tagstart++
-  for i := tagstart; i < len(tags); i++ {
-    if tags[i] == 0 {
-      r := Rawtag(tags[tagstart:i])
-      tagstart = i+1
-      if result == nil { result = data }
-      switch res := result.(type) {
-        case *UnmarshalledConstructed:
-          var found bool
-          result, found = res.Data[r]
-          if !found {
-            return nil
-          }
-        default:
+  split := splitTags(tags)
+  if len(split) == 0 { return nil }
+  for _, r := range split[1:] {
+    if result == nil { result = data }
+    switch res := result.(type) {
+      case *UnmarshalledConstructed:
+        var found bool
+        result, found = res.Data[r]
+        if !found {
           return nil
-      }
+        }
+      default:
+        return nil
     }
   }
   return result
@@ -619,7 +747,10 @@ func stripTags(data *UnmarshalledConstructed, tags []byte) Unmarshalled {
 // key exactly matches the tag of the child. If no such child is found it will use the
 // non-optional preceding children in the children list as context and will try to find
 // a key from in that contains all of the proper tags (see imperfectKeyMatch()).
-func mapRawtagsToNames(children []*Tree, in *UnmarshalledConstructed) map[string]interface{} {
+// If extensible is true, the elements from in that do not match any child are
+// unknown extension additions. They are mapped to the names "...0", "...1",... in order.
+// Otherwise they are ignored.
+func mapRawtagsToNames(children []*Tree, in *UnmarshalledConstructed, extensible bool) map[string]interface{} {
   if Debug {
     fmt.Fprintf(os.Stderr, "Mapping ")
     for n := range in.Data {
@@ -632,11 +763,11 @@ func mapRawtagsToNames(children []*Tree, in *UnmarshalledConstructed) map[string
     fmt.Fprintf(os.Stderr,"\n")
   }
   
-  used_unique_tags := map[int]bool{}
+  used_unique_tags := map[Rawtag]bool{}
   
   altkeys := [][]byte{}
   for key := range in.Data {
-    if key[len(key)-1] == 0 {
+    if key.isForm2() {
       altkeys = append(altkeys, []byte(key))
     }
   }
@@ -651,8 +782,7 @@ func mapRawtagsToNames(children []*Tree, in *UnmarshalledConstructed) map[string
     child_tag_bytes := []byte{}
     
     if len(c.tags) > 0 {  // a 0-length c.tags is possible for a CHOICE or ANY with no tag of its own
-      ft := 0
-      for c.tags[ft] != 0 { ft++ } // find end of first tag
+      ft := tagLength(c.tags) // find end of first tag
       // We only need the first tag. The others are modelled as sub-maps and will be handled by stripTags()
       first_tag := Rawtag(c.tags[0:ft])
       first_tag_bytes = c.tags[0:ft+1]
@@ -662,11 +792,11 @@ func mapRawtagsToNames(children []*Tree, in *UnmarshalledConstructed) map[string
         
       child, found = in.Data[first_tag]
       child_tag_bytes = first_tag_bytes
-      if found && used_unique_tags[child.Tag()] {
+      if found && used_unique_tags[child.RawTag()] {
         found = false // do not reuse elements
       }
       if found {
-        used_unique_tags[child.Tag()] = true
+        used_unique_tags[child.RawTag()] = true
       } else if Debug {
         fmt.Fprintf(os.Stderr, "%v not found as %x =>",c.name, c.tags[0:ft])
       }
@@ -692,23 +822,15 @@ func mapRawtagsToNames(children []*Tree, in *UnmarshalledConstructed) map[string
            len(altkeys[i]) < best &&
            imperfectKeyMatch(alternative_key, altkeys[i], len(first_tag_bytes)) {
              child2 := in.Data[Rawtag(altkeys[i])]
-             if !used_unique_tags[child2.Tag()] { // do not use an already used unique tag via altkeys
+             if !used_unique_tags[child2.RawTag()] { // do not use an already used unique tag via altkeys
                child = child2
                best = len(altkeys[i])
                best_i = i
                found = true
                if len(first_tag_bytes) == 0 {
                  // extract last tag from altkeys[i]
-                 child_tag_bytes = altkeys[i]
-                 k := 0
-                 for k < len(child_tag_bytes)-1 {
-                   if child_tag_bytes[k] == 0 {
-                     child_tag_bytes = child_tag_bytes[k+1:]
-                     k = 0
-                   } else {
-                     k++
-                   }
-                 }
+                 split := splitTags(altkeys[i])
+                 child_tag_bytes = altkeys[i][len(altkeys[i])-len(split[len(split)-1])-1:]
                }
              }
            }
@@ -725,7 +847,7 @@ func mapRawtagsToNames(children []*Tree, in *UnmarshalledConstructed) map[string
         
         altkeys[best_i] = nil // don't use the same element twice
         if _, is_unique := in.Data[Rawtag(child_tag_bytes[0:len(child_tag_bytes)-1])]; is_unique {
-          used_unique_tags[child.Tag()] = true
+          used_unique_tags[child.RawTag()] = true
         }
       }
     }
@@ -745,6 +867,28 @@ func mapRawtagsToNames(children []*Tree, in *UnmarshalledConstructed) map[string
       }
     }
   }
+  
+  if extensible {
+    used := map[Unmarshalled]bool{}
+    for _, child := range out {
+      used[child.(Unmarshalled)] = true
+    }
+    // sort altkeys by increasing length to recreate the original order (see instantiateSEQUENCE_OF())
+    keys := make([]Rawtag, 0, len(in.Data))
+    for key := range in.Data {
+      if key.isForm2() {
+        keys = append(keys, key)
+      }
+    }
+    sort.Slice(keys, func(i, j int) bool { return len(keys[i]) < len(keys[j]) })
+    n := 0
+    for _, key := range keys {
+      if !used[in.Data[key]] {
+        out[fmt.Sprintf("...%d", n)] = in.Data[key]
+        n++
+      }
+    }
+  }
   return out
 }
 
@@ -757,56 +901,28 @@ func mapRawtagsToNames(children []*Tree, in *UnmarshalledConstructed) map[string
 // always assumed to be true if taglen==0) and all tags from alternative_key occur in
 // the same order within key.
 func imperfectKeyMatch(alternative_key []byte, key []byte, taglen int) bool {
-  // First check if key and alternative_key end in the same tag of length taglen
-  if len(alternative_key) < taglen || len(key) < taglen { return false }
-  for i := 1; i <= taglen; i++ {
-    if alternative_key[len(alternative_key)-i] != key[len(key)-i] { return false }
-  }
+  atags := splitTags(alternative_key)
+  ktags := splitTags(key)
   
-  // make sure there's a tag boundary
-  if len(key) > taglen && key[len(key)-1-taglen] != 0 { return false }
-  
-  // Now check if all other tags in alternative key are found somewhere in the same order within key
-  
-  // First chop off the last tag of alternative_key which has already been verified above
-  alternative_key = alternative_key[0:len(alternative_key)-taglen]
+  // First check if key and alternative_key end in the same tag
+  if taglen > 0 {
+    if len(atags) == 0 || len(ktags) == 0 || atags[len(atags)-1] != ktags[len(ktags)-1] { return false }
+    // chop off the last tag of alternative_key which has already been verified
+    atags = atags[0:len(atags)-1]
+  }
   
-  // Now chop off the last tag of key. We CAN NOT simply chop off the last taglen characters
-  // as we did above for alternative_key because there is the special case of
-  // taglen == 0 which means that the last tag in alternative_key is missing because we're dealing with
+  // Now chop off the last tag of key. This is done even if taglen == 0, which means
+  // that the last tag in alternative_key is missing because we're dealing with
   // an ANY or CHOICE whose tag depends on the contents. In that case the last tag is a wildcard.
-  // So we need to chop off the last tag of key without referring to taglen
-  i := len(key)-2
-  for ; i >= 0; i-- {
-    if key[i] == 0 {
-      key = key[0:i+1]
-      break
-    }
-  }
-  if i < 0 { key = key[0:0] }
+  if len(ktags) > 0 { ktags = ktags[0:len(ktags)-1] }
   
+  // Now check if all other tags in alternative key are found somewhere in the same order within key
   a := 0
-  k := 0
-  for a < len(alternative_key) && k < len(key) {
-    i := 0
-    for a+i < len(alternative_key) && k+i < len(key) && alternative_key[a+i] == key[k+i] {
-      if alternative_key[a+i] == 0 {
-        a = a+i+1
-        break
-      }
-      i++
-    }
-    
-    // advance k to start of next tag
-    for k < len(key) && key[k] != 0 { k++ }
-    k++
+  for _, k := range ktags {
+    if a < len(atags) && atags[a] == k { a++ }
   }
   
-  if a == len(alternative_key) {
-    return true
-  }
-  
-  return false
+  return a == len(atags)
 }
 
 
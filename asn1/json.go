@@ -22,6 +22,7 @@ import (
          "fmt"
          "sort"
          "strings"
+         "time"
          "math/big"
          "encoding/json"
        )
@@ -99,6 +100,18 @@ func (i *Instance) JSON(params ...interface{}) string {
 // withType => output type information for proper ANY instantiation
 func jsonInstance(s *[]string, t *Tree, jp *jsonParams, withType bool) {
   withTypeOrAny := withType || t.isAny
+  if t.isRaw {
+    // The type is unknown, so the only lossless representation is the DER encoding.
+    // decode(DER) turns it back into the Unmarshalled the instance was created from.
+    *s = append(*s, "\"$'0x")
+    space := ""
+    for _, b := range (*Instance)(t).DER() {
+      *s = append(*s, fmt.Sprintf("%v%02X", space, b))
+      space = " "
+    }
+    *s = append(*s, "' decode(hex) decode(DER)\"")
+    return
+  }
   switch t.basictype {
     case SEQUENCE, SET, CHOICE:
       saveMrOID := ""
@@ -121,6 +134,7 @@ func jsonInstance(s *[]string, t *Tree, jp *jsonParams, withType bool) {
         *s = append(*s, "\"", c.name, "\": ")
         
         decoded_DER := false
+        decoded_elements := false
         if decode, ok := derInDER[c.name][jp.mrOID]; ok {
           var data []byte
           switch d := c.value.(type) {
@@ -151,9 +165,37 @@ func jsonInstance(s *[]string, t *Tree, jp *jsonParams, withType bool) {
               saveMrOID = jp.mrOID // OIDs within the recursively decoded block do not matter outside
             }
           } 
+          
+          // The ANY elements of a SET OF or SEQUENCE OF (e.g. the values of an Attribute)
+          // are decoded individually. They remain ANY, so they do not need encode(DER).
+          if (c.basictype == SET_OF || c.basictype == SEQUENCE_OF) && len(c.children) > 0 {
+            children := make([]*Tree, len(c.children))
+            for i, e := range c.children {
+              var instance *Instance
+              if e.isAny {
+                instance = decode((*Instance)(e).DER())
+              }
+              if instance == nil {
+                children = nil
+                break
+              }
+              instance.isAny = true
+              children[i] = (*Tree)(instance)
+            }
+            if children != nil {
+              decoded_elements = true
+              c2 := *c
+              c2.children = children
+              c = &c2
+              saveMrOID = jp.mrOID
+            }
+          }
         }
         
         jsonInstance(s, c, jp, withType)
+        if decoded_elements {
+          jp.mrOID = saveMrOID
+        }
         if decoded_DER {
           jp.mrOID = saveMrOID
           childCode := (*s)[len(*s)-1]
@@ -260,14 +302,23 @@ func jsonValue(s *[]string, t *Tree, jp *jsonParams, withType bool) {
                  err := json.Unmarshal(enc, &dec)
                  if err != nil { panic(err) }
                  tn := typeName(t)
-                 if len(v) == 4 && len(enc) != 6 { // possible IPv4 address. The len(enc) != 6 test makes sure we only enter this case if the marshalling is "ugly", i.e. contains escape sequences
+                 if tm, err := ParseDERTime(timeTag(t), string(v)); err == nil {
+                   // Only DER-conformant times are printed in RFC 3339 format. Other
+                   // time values are printed verbatim so that they are reproduced exactly
+                   // when the JSON is converted back.
+                   if withTypeOrAny {
+                     *s = append(*s, fmt.Sprintf("\"$'%v' %v\"", tm.Format(time.RFC3339Nano), tn))
+                   } else {
+                     *s = append(*s, fmt.Sprintf("\"%v\"", tm.Format(time.RFC3339Nano)))
+                   }
+                 } else if len(v) == 4 && len(enc) != 6 { // possible IPv4 address. The len(enc) != 6 test makes sure we only enter this case if the marshalling is "ugly", i.e. contains escape sequences
                    *s = append(*s, "\"$")
                    *s = append(*s, fmt.Sprintf("%d.%d.%d.%d", v[0], v[1], v[2], v[3]))
                    if withTypeOrAny {
                      *s = append(*s, " ", typeName(t))
                    }
                    *s = append(*s, "\"")
-                 } else if string(v) == dec {
+                 } else if string(v) == dec && timeTag(t) == 0 {
                    if (len(v) > 0 && v[0] == '$') || (withTypeOrAny && tn != "UTF8String") {
                      // remove the quotes surrounding enc
                      enc = enc[1:len(enc)-1]
@@ -279,6 +330,7 @@ func jsonValue(s *[]string, t *Tree, jp *jsonParams, withType bool) {
                      *s = append(*s, string(enc))
                    }
                  } else { // if the data contains invalid UTF-8 sequences and cannot be marshalled losslessly
+                          // or if it is a time that is not DER-conformant (which would be rejected if given as string)
                    *s = append(*s, "\"$'0x")
                    space := ""
                    for _, b := range v {
@@ -61,9 +61,16 @@ func NewParseError(src string, pos int, format string, a ...interface{}) (*Parse
 }
 
 /*
-Takes ASN.1 source beginning with "DEFINITIONS" and ending with "END" and parses
-the contained value and type definitions, adding them to the already existing
-definitions (if any). Any error that is returned is always of type *ParseError.
+Takes ASN.1 source consisting of one or more modules and parses the contained
+value and type definitions, adding them to the already existing definitions (if any).
+Each module begins with an optional module identifier (e.g. "PKIX1Explicit88 { iso(1) ... }"),
+followed by "DEFINITIONS", and ends with "END". IMPORTS and EXPORTS are supported.
+References to types and values are resolved within the referencing module first, then
+among its IMPORTS and finally among all definitions. This means that modules that
+define types or values of the same name can be used together as long as each module has a name.
+SEQUENCE, SET, CHOICE and ENUMERATED types may be extensible, i.e. contain the extension
+marker "..." and extension additions (also in groups "[[ ... ]]").
+Any error that is returned is always of type *ParseError.
 If an error occurs before "END", the Definitions object is
 in an undefined state (and should not be used any more).
 If an error occurs after the "END", the Definitions object is valid and the
@@ -96,6 +103,12 @@ func (defs *Definitions) Parse(asn1src string) error {
   
   defs.addUniversalTypes(asn1src, len(asn1src))
   
+  if resolve_err := defs.resolveImports(); resolve_err != nil {
+    return resolve_err
+  }
+  
+  defs.resolveAutomaticTags()
+  
   if resolve_err := defs.resolveTypes(); resolve_err != nil {
     return resolve_err
   }
@@ -119,6 +132,13 @@ func (defs *Definitions) Parse(asn1src string) error {
     return resolve_err
   }
   
+  resolved := map[*Tree]bool{}
+  for _, t := range defs.typedefs {
+    if resolve_err := defs.resolveTypeConstraints(t, resolved); resolve_err != nil {
+      return resolve_err
+    }
+  }
+  
   for _, t := range defs.typedefs {
     if resolve_err := defs.resolveFields(t); resolve_err != nil {
       return resolve_err
@@ -167,12 +187,24 @@ var tokDEFINITIONS = &token{
   parseDEFINITIONS,
 }
 
+var tokModuleIdentifier = &token{
+  regexp.MustCompile(`^` + upperCaseIdentifier + `(\s*\{[^{}]*\})?`),
+  "module name",
+  parseModuleIdentifier,
+}
+
 var tokIMPLICITTAGS = &token{
   regexp.MustCompile(`^IMPLICIT\s+TAGS\b`),
   "'IMPLICIT TAGS'",
   parseIMPLICITTAGS,
 }
 
+var tokAUTOMATICTAGS = &token{
+  regexp.MustCompile(`^AUTOMATIC\s+TAGS\b`),
+  "'AUTOMATIC TAGS'",
+  parseAUTOMATICTAGS,
+}
+
 var tokEXPLICITTAGS = &token{
   regexp.MustCompile(`^EXPLICIT\s+TAGS\b`),
   "'EXPLICIT TAGS'",
@@ -193,6 +225,46 @@ var tokBEGIN = &token{
   parseBEGIN,
 }
 
+var tokEXPORTS = &token{
+  regexp.MustCompile(`^EXPORTS\b`),
+  "'EXPORTS'",
+  parseEXPORTS,
+}
+
+var tokALL = &token{
+  regexp.MustCompile(`^ALL\b`),
+  "'ALL'",
+  parseALL,
+}
+
+var tokIMPORTS = &token{
+  regexp.MustCompile(`^IMPORTS\b`),
+  "'IMPORTS'",
+  parseIMPORTS,
+}
+
+// A reference to a type or value in EXPORTS or IMPORTS. A trailing "{}" (which marks
+// a parameterized reference) is accepted and ignored.
+const symbol = `^([[:alpha:]][0-9a-zA-Z-]*\b)(\s*\{\s*\})?`
+
+var tokExportedSymbol = &token{
+  regexp.MustCompile(symbol),
+  "symbol",
+  parseExportedSymbol,
+}
+
+var tokImportedSymbol = &token{
+  regexp.MustCompile(symbol),
+  "symbol",
+  parseImportedSymbol,
+}
+
+var tokFROM = &token{
+  regexp.MustCompile(`^FROM\s+` + upperCaseIdentifier + `(\s*\{[^{}]*\})?(\s+WITH\s+(SUCCESSORS|DESCENDANTS)\b)?`),
+  "'FROM'",
+  parseFROM,
+}
+
 var tokEND = &token{
   regexp.MustCompile(`^END\b`),
   "'END'",
@@ -219,7 +291,7 @@ var tok__PLICIT = &token{
   parse__PLICIT,
 }
 
-const typeIdentifier = `^((BOOLEAN\b)|(NULL\b)|((OCTET\s+STRING)\b)|((OBJECT\s+IDENTIFIER)\b)|(ANY\s+DEFINED\s+BY\s+`+lowerCaseIdentifier+`)|(((INTEGER)|(BIT\s+STRING)|(ENUMERATED))(\s*\{)?)|((SEQUENCE|SET)(\s+SIZE\s*\([^)]+\))?((\s+OF\b)|(\s*\{)))|(CHOICE\s*\{)|`+ upperCaseIdentifier  +`)`
+const typeIdentifier = `^((BOOLEAN\b)|(NULL\b)|((OCTET\s+STRING)\b)|((OBJECT\s+IDENTIFIER)\b)|(ANY\s+DEFINED\s+BY\s+`+lowerCaseIdentifier+`)|(((INTEGER)|(BIT\s+STRING)|(ENUMERATED))(\s*\{)?)|((SEQUENCE|SET)(\s+SIZE\s*\([^)]+\))?((\s+OF\b)|(\s*\{)))|(CHOICE\s*\{)|(`+ upperCaseIdentifier + `\.)?` + upperCaseIdentifier  +`)`
 
 func tokTypeDef(nextState *state) (*token) {
   return &token{
@@ -319,12 +391,38 @@ var tokLabelledInt = &token{
   parseLabelledInt,
 }
 
+var tokExtensionMarker = &token{
+  // The optional exception specification ("! ...") is accepted and ignored.
+  regexp.MustCompile(`^\.\.\.(\s*!\s*(-?[0-9]+|` + lowerCaseIdentifier + `))?`),
+  "'...'",
+  parseExtensionMarker,
+}
+
+var tokExtensionGroup = &token{
+  // The optional version number ("[[2: ...") is accepted and ignored.
+  regexp.MustCompile(`^\[\[(\s*[0-9]+\s*:)?`),
+  "'[['",
+  parseExtensionGroup,
+}
+
 var tokCommaDontEat = &token{
   regexp.MustCompile(`^[,]`),
   "','",
   parseDontEat,
 }
 
+var tokSemicolonDontEat = &token{
+  regexp.MustCompile(`^[;]`),
+  "';'",
+  parseDontEat,
+}
+
+var tokGroupCloseDontEat = &token{
+  regexp.MustCompile(`^\]\]`),
+  "']]'",
+  parseDontEat,
+}
+
 var tokCurlyCloseDontEat = &token{
   regexp.MustCompile(`^[}]`),
   "'}'",
@@ -337,6 +435,12 @@ var tokNotParenDontEat = &token{
   parseDontEat,
 }
 
+var tokNothingDontEat = &token{
+  regexp.MustCompile(`^`),
+  "something",
+  parseDontEat,
+}
+
 var tokEOF = &token{
   regexp.MustCompile("^$"),
   "End of File",
@@ -352,10 +456,17 @@ func (t *token) String() string {
 // parser state list, this results in the familiar "... expected" parser error.
 type state []*token
 
-var stateStart = state{tokComment, tokDEFINITIONS}
-var stateDEFINITIONS = state{tokComment, tokIMPLICITTAGS, tokEXPLICITTAGS}
+var stateStart = state{tokComment, tokDEFINITIONS, tokModuleIdentifier}
+var stateModuleIdentifier = state{tokComment, tokDEFINITIONS}
+var stateDEFINITIONS = state{tokComment, tokIMPLICITTAGS, tokEXPLICITTAGS, tokAUTOMATICTAGS, tokCoCoEq(&stateBEGIN)}
 var stateCoCoEq = state{tokComment, tokCoCoEq(&stateBEGIN)}
 var stateBEGIN = state{tokComment, tokBEGIN}
+var stateEXPORTS = state{tokComment, tokEXPORTS, tokNothingDontEat}
+var stateExportedSymbols = state{tokComment, tokALL, tokExportedSymbol, tokSemicolonDontEat}
+var stateExportedSymbolPost = state{tokComment, tokCommaDontEat, tokSemicolonDontEat}
+var stateIMPORTS = state{tokComment, tokIMPORTS, tokNothingDontEat}
+var stateImportedSymbols = state{tokComment, tokImportedSymbol, tokSemicolonDontEat}
+var stateImportedSymbolPost = state{tokComment, tokCommaDontEat, tokFROM}
 var stateMain = state{tokComment, tokEND, tokTypeName, tokValueName}
 var stateEnd = state{tokComment, tokEOF}
 var stateTypeDefPre = state{tokComment, tokCoCoEq(&stateTypeDef)}
@@ -365,11 +476,13 @@ var stateTypePost = state{tokComment, tokSIZE, tokRange, tokNotParenDontEat}
 var stateValueType = state{tokComment, tokValueType}
 var stateValueDefPre = state{tokComment, tokCoCoEq(&stateValueDef)}
 var stateValueDef = state{tokComment, tokValueInteger, tokValueBoolean, tokValueNull, tokValueString, tokValueReference, tokValueOID}
-var stateStructure = state{tokComment, tokFieldName}
+var stateStructure = state{tokComment, tokFieldName, tokExtensionMarker, tokExtensionGroup}
 var stateFieldDef state
 var stateFieldDef2 = state{tokComment, tokTag, tok__PLICIT, tokTypeDef(&stateFieldPost) }
-var stateFieldPost = state{tokComment, tokDEFAULT, tokOPTIONAL, tokSIZE, tokRange, tokCommaDontEat, tokCurlyCloseDontEat}
-var stateLabelledInts = state{tokComment, tokLabelledInt}
+var stateFieldPost = state{tokComment, tokDEFAULT, tokOPTIONAL, tokSIZE, tokRange, tokCommaDontEat, tokCurlyCloseDontEat, tokGroupCloseDontEat}
+var stateExtensionPost = state{tokComment, tokCommaDontEat, tokCurlyCloseDontEat}
+var stateExtensionGroup = state{tokComment, tokFieldName}
+var stateLabelledInts = state{tokComment, tokLabelledInt, tokExtensionMarker}
 var stateLabelledIntPost = state{tokComment, tokCommaDontEat, tokCurlyCloseDontEat}
 
 // This is required to break definition loops (stateTypeDef => tokTypeDef => parseTypeDef => parseTypeDefStatic => stateTypeDef)
@@ -388,8 +501,22 @@ func parseDontEat(implicit bool, src string, pos int, match string, stat state,
   return pos, nil
 }
 
+func parseModuleIdentifier(implicit bool, src string, pos int, match string, stat state, tok *token, tree *Tree) (int, error) { 
+  name := tokTypeName.Regex.FindString(match)
+  header := &moduleHeader{name:name, oid:strings.TrimSpace(match[len(name):]), src:src, pos:pos}
+  child := &Tree{ src:src, pos:pos, nodetype:moduleNode, source_tag: -1, name: name, module: header }
+  tree.children = append(tree.children, child)
+  return parseRecursive(implicit, src, pos+len(match), stateModuleIdentifier, child)
+}
+
 func parseDEFINITIONS(implicit bool, src string, pos int, match string, stat state, tok *token, tree *Tree) (int, error) { 
-  return parseRecursive(implicit, src, pos+len(match), stateDEFINITIONS, tree)
+  if tree.nodetype == rootNode { // module without module identifier
+    child := &Tree{ src:src, pos:pos, nodetype:moduleNode, source_tag: -1, module: &moduleHeader{src:src, pos:pos} }
+    tree.children = append(tree.children, child)
+    tree = child
+  }
+  // false because without "IMPLICIT TAGS" or "EXPLICIT TAGS" the default is EXPLICIT (X.680 12.3)
+  return parseRecursive(false, src, pos+len(match), stateDEFINITIONS, tree)
 }
 
 // eat the token and parse recursively in nextState
@@ -403,12 +530,74 @@ func parseBEGIN(implicit bool, src string, pos int, match string, stat state, to
   tree.implicit = implicit
   var err error
   pos += len(match)
+  pos, err = parseRecursive(implicit, src, pos, stateEXPORTS, tree)
+  if err != nil { return pos, err }
+  pos, err = parseRecursive(implicit, src, pos, stateIMPORTS, tree)
   for err == nil && pos < len(src) {
     pos, err = parseRecursive(implicit, src, pos, stateMain, tree)
   }
   return pos, err
 }
 
+func parseEXPORTS(implicit bool, src string, pos int, match string, stat state, tok *token, tree *Tree) (int, error) { 
+  tree.module.exports = map[string]bool{}
+  pos += len(match)
+  var err error
+  for {
+    pos, err = parseRecursive(implicit, src, pos, stateExportedSymbols, tree)
+    if err != nil { return pos, err }
+    if src[pos] == ';' { 
+      return pos+1, nil
+    } else { // src[pos] == ','
+      pos++
+    }
+  }
+}
+
+// "EXPORTS ALL" is the same as having no EXPORTS clause.
+func parseALL(implicit bool, src string, pos int, match string, stat state, tok *token, tree *Tree) (int, error) { 
+  tree.module.exports = nil
+  return parseRecursive(implicit, src, pos+len(match), state{tokComment, tokSemicolonDontEat}, tree)
+}
+
+func parseExportedSymbol(implicit bool, src string, pos int, match string, stat state, tok *token, tree *Tree) (int, error) { 
+  tree.module.exports[tok.Regex.FindStringSubmatch(match)[1]] = true
+  return parseRecursive(implicit, src, pos+len(match), stateExportedSymbolPost, tree)
+}
+
+func parseIMPORTS(implicit bool, src string, pos int, match string, stat state, tok *token, tree *Tree) (int, error) { 
+  tree.module.imports = map[string]*importedSymbol{}
+  pos += len(match)
+  var err error
+  for {
+    pos, err = parseRecursive(implicit, src, pos, stateImportedSymbols, tree)
+    if err != nil { return pos, err }
+    if pos >= len(src) { continue } // the next parseRecursive() will report the premature end
+    if src[pos] == ';' { 
+      return pos+1, nil
+    } else if src[pos] == ',' {
+      pos++
+    } // else the preceding symbol was followed by FROM ... and the next symbol (or ';') follows
+  }
+}
+
+func parseImportedSymbol(implicit bool, src string, pos int, match string, stat state, tok *token, tree *Tree) (int, error) { 
+  name := tok.Regex.FindStringSubmatch(match)[1]
+  if _, exists := tree.module.imports[name]; exists {
+    return pos, NewParseError(src, pos, "Symbol '%v' imported more than once", name)
+  }
+  tree.module.imports[name] = &importedSymbol{pos:pos} // module is filled in by parseFROM()
+  return parseRecursive(implicit, src, pos+len(match), stateImportedSymbolPost, tree)
+}
+
+func parseFROM(implicit bool, src string, pos int, match string, stat state, tok *token, tree *Tree) (int, error) { 
+  module := tok.Regex.FindStringSubmatch(match)[1]
+  for _, imp := range tree.module.imports {
+    if imp.module == "" { imp.module = module }
+  }
+  return pos+len(match), nil
+}
+
 func parseEND(implicit bool, src string, pos int, match string, stat state, tok *token, tree *Tree) (int, error) { 
   return parseRecursive(implicit, src, pos+len(match), stateEnd, tree)
 }
@@ -421,8 +610,13 @@ func parseEXPLICITTAGS(implicit bool, src string, pos int, match string, stat st
   return parseRecursive(false, src, pos+len(match), stateCoCoEq, tree)
 }
 
+func parseAUTOMATICTAGS(implicit bool, src string, pos int, match string, stat state, tok *token, tree *Tree) (int, error) { 
+  tree.module.automatic = true
+  return parseRecursive(true, src, pos+len(match), stateCoCoEq, tree)
+}
+
 func parseTypeName(implicit bool, src string, pos int, match string, stat state, tok *token, tree *Tree) (int, error) { 
-  child := &Tree{ src:src, pos:pos, nodetype:typeDefNode, source_tag: -1, implicit: implicit, /*NOT typename!!*/name: match }
+  child := &Tree{ src:src, pos:pos, nodetype:typeDefNode, source_tag: -1, implicit: implicit, /*NOT typename!!*/name: match, module: tree.module }
   tree.children = append(tree.children, child)
   return parseRecursive(implicit, src, pos+len(match), stateTypeDefPre, child)
 }
@@ -453,8 +647,8 @@ func parseTag(implicit bool, src string, pos int, match string, stat state, tok
       if err != nil { 
         return pos, NewParseError(src, pos, "Illegal tag number: %v", err)
       }
-      if num < 0 || num > 63 { return pos, NewParseError(src, pos, "Tag number not in range [0..63]: %v", num) }
-      tree.source_tag += num
+      if num < 0 || num > 0xFFFFFF { return pos, NewParseError(src, pos, "Tag number not in range [0..16777215]: %v", num) }
+      tree.source_tag += sourceTagNumber(num)
     }
   }
   if tree.source_tag == 0 { return pos, NewParseError(src, pos, "UNIVERSAL 0 is reserved") }
@@ -484,11 +678,15 @@ func parseDEFAULT(implicit bool, src string, pos int, match string, stat state,
   return parseRecursive(implicit, src, pos, state_without_tok(stat,tok), tree)
 }
 
+var sizeConstraint = regexp.MustCompile(`SIZE\s*\(([^)]+)\)`)
+
 func parseSIZE(implicit bool, src string, pos int, match string, stat state, tok *token, tree *Tree) (int, error) { 
+  tree.size = &constraint{src:sizeConstraint.FindStringSubmatch(match)[1], pos:pos}
   return parseRecursive(implicit, src, pos+len(match), state_without_tok(stat,tok), tree)
 }
 
 func parseRange(implicit bool, src string, pos int, match string, stat state, tok *token, tree *Tree) (int, error) { 
+  tree.valueRange = &constraint{src:match[1:len(match)-1], pos:pos}
   return parseRecursive(implicit, src, pos+len(match), state_without_tok(stat,tok), tree)
 }
 
@@ -516,6 +714,7 @@ func parseTypeDefStatic(implicit bool, src string, pos int, match string, stat s
   last := spl[len(spl)-1]
   if typ == "OCTET STRING" {
     tree.basictype = OCTET_STRING
+    tree.stringTag = BasicTypeTag[OCTET_STRING]
   } else if typ == "BOOLEAN" {
     tree.basictype = BOOLEAN
   } else if typ == "NULL" {
@@ -549,7 +748,10 @@ func parseTypeDefStatic(implicit bool, src string, pos int, match string, stat s
   } else if first == "SEQUENCE" || first == "SET" || first == "CHOICE" {
     if last == "OF" {
       if first == "SEQUENCE" { tree.basictype = SEQUENCE_OF } else { tree.basictype = SET_OF }
-      child := &Tree{ src:src, pos:pos, nodetype: ofNode, source_tag: -1, implicit: implicit }
+      if sm := sizeConstraint.FindStringSubmatch(match); sm != nil {
+        tree.size = &constraint{src:sm[1], pos:pos-len(match)}
+      }
+      child := &Tree{ src:src, pos:pos, nodetype: ofNode, source_tag: -1, implicit: implicit, module: tree.module }
       tree.children = append(tree.children, child)
       return parseRecursive(implicit, src, pos, stateTypeDef, child)
     } else {
@@ -573,6 +775,8 @@ func parseTypeDefStatic(implicit bool, src string, pos int, match string, stat s
         
         if src[pos] == /*{*/ '}' { 
           return pos+1, nil
+        } else if src[pos] == ']' {
+          return pos, NewParseError(src, pos, "']]' without matching '[['")
         } else { // src[pos] == ','
           pos++
         }
@@ -591,7 +795,7 @@ func parseTypeDefStatic(implicit bool, src string, pos int, match string, stat s
 }
 
 func parseValueName(implicit bool, src string, pos int, match string, stat state, tok *token, tree *Tree) (int, error) { 
-  child := &Tree{ src:src, pos:pos, nodetype: valueDefNode, source_tag: -1, implicit: implicit, name: match }
+  child := &Tree{ src:src, pos:pos, nodetype: valueDefNode, source_tag: -1, implicit: implicit, name: match, module: tree.module }
   tree.children = append(tree.children, child)
   return parseRecursive(implicit, src, pos+len(match), stateValueType, child)
 }
@@ -600,6 +804,7 @@ func parseValueType(implicit bool, src string, pos int, match string, stat state
   switch(match) {
     case "OBJECT IDENTIFIER": tree.basictype = OBJECT_IDENTIFIER
     case "OCTET STRING": tree.basictype = OCTET_STRING
+                         tree.stringTag = BasicTypeTag[OCTET_STRING]
     case "BIT STRING": tree.basictype = BIT_STRING
     case "ENUMERATED": tree.basictype = ENUMERATED
     case "INTEGER": tree.basictype = INTEGER
@@ -622,7 +827,7 @@ func parseValueDef(implicit bool, src string, pos int, match string, stat state,
 }
 
 func parseFieldName(implicit bool, src string, pos int, match string, stat state, tok *token, tree *Tree) (int, error) { 
-  child := &Tree{ src:src, pos:pos, nodetype: fieldNode, source_tag: -1, implicit: implicit, name: match }
+  child := &Tree{ src:src, pos:pos, nodetype: fieldNode, source_tag: -1, implicit: implicit, name: match, extension: tree.extensionMarkers == 1, module: tree.module }
   tree.children = append(tree.children, child)
   return parseRecursive(implicit, src, pos+len(match), stateFieldDef, child)
 }
@@ -642,6 +847,39 @@ func parseLabelledInt(implicit bool, src string, pos int, match string, stat sta
   return parseRecursive(implicit, src, pos+len(match), stateLabelledIntPost, tree)
 }
 
+func parseExtensionMarker(implicit bool, src string, pos int, match string, stat state, tok *token, tree *Tree) (int, error) { 
+  switch tree.basictype {
+    case SEQUENCE, SET, CHOICE, ENUMERATED:
+    default: return pos, NewParseError(src, pos, "Extension marker '...' is not permitted in %v", BasicTypeName[tree.basictype])
+  }
+  if tree.extensionMarkers == 2 || (tree.basictype == ENUMERATED && tree.extensionMarkers == 1) {
+    return pos, NewParseError(src, pos, "Too many extension markers '...'")
+  }
+  tree.extensionMarkers++
+  return parseRecursive(implicit, src, pos+len(match), stateExtensionPost, tree)
+}
+
+// The components of an extension addition group are added to tree like
+// extension additions that are not in a group.
+func parseExtensionGroup(implicit bool, src string, pos int, match string, stat state, tok *token, tree *Tree) (int, error) { 
+  if tree.extensionMarkers != 1 {
+    return pos, NewParseError(src, pos, "Extension addition group '[[...]]' is only permitted after the extension marker '...'")
+  }
+  pos += len(match)
+  var err error
+  for {
+    pos, err = parseRecursive(implicit, src, pos, stateExtensionGroup, tree)
+    if err != nil { return pos, err }
+    if src[pos] == ']' {
+      return parseRecursive(implicit, src, pos+2, stateExtensionPost, tree)
+    } else if src[pos] == /*{*/ '}' {
+      return pos, NewParseError(src, pos, "Expected ']]' to close extension addition group")
+    } else { // src[pos] == ','
+      pos++
+    }
+  }
+}
+
 func lineCol(src string, pos int) string {
   col := 0
   line := 1
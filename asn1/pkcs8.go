@@ -0,0 +1,225 @@
+/*
+Copyright (c) 2015 Matthias S. Benkmann
+
+This program is free software; you can redistribute it and/or
+modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation; version 3
+of the License (ONLY this version).
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+*/
+
+/*
+  This file contains the code for encrypting and decrypting private keys
+  in PKCS#8 EncryptedPrivateKeyInfo format (RFC 5958) using
+  PBES2 with PBKDF2 and AES-CBC (RFC 8018).
+*/
+
+package asn1
+
+import (
+         "fmt"
+         "bytes"
+         "hash"
+         "crypto/aes"
+         "crypto/cipher"
+         "crypto/hmac"
+         "crypto/rand"
+         "crypto/sha1"
+         "crypto/sha256"
+         "crypto/sha512"
+         "crypto/x509/pkix"
+         stdasn1 "encoding/asn1"
+       )
+
+// Number of PBKDF2 iterations used by EncryptPKCS8().
+const PBKDF2Iterations = 100000
+
+// Maximum number of PBKDF2 iterations accepted by DecryptPKCS8(). Larger values
+// would let a crafted key file keep the CPU busy for hours.
+const MaxPBKDF2Iterations = 10000000
+
+var (
+  oidPBES2 = stdasn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+  oidPBKDF2 = stdasn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+)
+
+// Maps the OIDs of the PRFs supported for PBKDF2 to their hash functions.
+var pbkdf2PRFs = map[string]func() hash.Hash{
+  "1.2.840.113549.2.7": sha1.New,   // hmacWithSHA1
+  "1.2.840.113549.2.9": sha256.New, // hmacWithSHA256
+  "1.2.840.113549.2.10": sha512.New384, // hmacWithSHA384
+  "1.2.840.113549.2.11": sha512.New, // hmacWithSHA512
+}
+
+// Maps the OIDs of the supported AES-CBC ciphers to their key lengths.
+var pbes2Ciphers = map[string]int{
+  "2.16.840.1.101.3.4.1.2": 16,  // aes128-CBC
+  "2.16.840.1.101.3.4.1.22": 24, // aes192-CBC
+  "2.16.840.1.101.3.4.1.42": 32, // aes256-CBC
+}
+
+type encryptedPrivateKeyInfo struct {
+  EncryptionAlgorithm pkix.AlgorithmIdentifier
+  EncryptedData []byte
+}
+
+type pbes2Params struct {
+  KeyDerivationFunc pkix.AlgorithmIdentifier
+  EncryptionScheme pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+  Salt []byte
+  IterationCount int
+  KeyLength int `asn1:"optional"`
+  PRF pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// Returns true iff der is a PKCS#8 EncryptedPrivateKeyInfo with PBES2 encryption.
+func IsEncryptedPKCS8(der []byte) bool {
+  var info encryptedPrivateKeyInfo
+  rest, err := stdasn1.Unmarshal(der, &info)
+  return err == nil && len(rest) == 0 && info.EncryptionAlgorithm.Algorithm.Equal(oidPBES2)
+}
+
+// Encrypts the DER-encoded PKCS#8 PrivateKeyInfo privkey with passphrase
+// using PBES2 with PBKDF2 (HMAC-SHA256, random salt, PBKDF2Iterations)
+// and AES-256-CBC. Returns the DER encoding of the EncryptedPrivateKeyInfo.
+func EncryptPKCS8(privkey []byte, passphrase []byte) ([]byte, error) {
+  salt := make([]byte, 16)
+  iv := make([]byte, aes.BlockSize)
+  if _, err := rand.Read(salt); err != nil {
+    return nil, err
+  }
+  if _, err := rand.Read(iv); err != nil {
+    return nil, err
+  }
+
+  key := pbkdf2(passphrase, salt, PBKDF2Iterations, 32, sha256.New)
+  block, err := aes.NewCipher(key)
+  if err != nil {
+    return nil, err
+  }
+
+  // PKCS#7 padding
+  padlen := aes.BlockSize - len(privkey) % aes.BlockSize
+  encrypted := append(append([]byte{}, privkey...), bytes.Repeat([]byte{byte(padlen)}, padlen)...)
+  cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, encrypted)
+
+  ivbytes, err := stdasn1.Marshal(iv)
+  if err != nil {
+    return nil, err
+  }
+  kdfparams, err := stdasn1.Marshal(pbkdf2Params{Salt:salt, IterationCount:PBKDF2Iterations,
+                      PRF:pkix.AlgorithmIdentifier{Algorithm:stdasn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}, Parameters:stdasn1.NullRawValue}})
+  if err != nil {
+    return nil, err
+  }
+  params, err := stdasn1.Marshal(pbes2Params{
+                  KeyDerivationFunc:pkix.AlgorithmIdentifier{Algorithm:oidPBKDF2, Parameters:stdasn1.RawValue{FullBytes:kdfparams}},
+                  EncryptionScheme:pkix.AlgorithmIdentifier{Algorithm:stdasn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}, Parameters:stdasn1.RawValue{FullBytes:ivbytes}}})
+  if err != nil {
+    return nil, err
+  }
+
+  return stdasn1.Marshal(encryptedPrivateKeyInfo{
+           EncryptionAlgorithm:pkix.AlgorithmIdentifier{Algorithm:oidPBES2, Parameters:stdasn1.RawValue{FullBytes:params}},
+           EncryptedData:encrypted})
+}
+
+// Decrypts the DER-encoded PKCS#8 EncryptedPrivateKeyInfo encrypted with passphrase
+// and returns the DER encoding of the contained PrivateKeyInfo.
+// Only PBES2 with PBKDF2 and AES-CBC is supported.
+func DecryptPKCS8(encrypted []byte, passphrase []byte) ([]byte, error) {
+  var info encryptedPrivateKeyInfo
+  if _, err := stdasn1.Unmarshal(encrypted, &info); err != nil {
+    return nil, fmt.Errorf("Not an EncryptedPrivateKeyInfo: %v", err)
+  }
+  if !info.EncryptionAlgorithm.Algorithm.Equal(oidPBES2) {
+    return nil, fmt.Errorf("Unsupported key encryption algorithm %v (only PBES2 is supported)", info.EncryptionAlgorithm.Algorithm)
+  }
+
+  var params pbes2Params
+  if _, err := stdasn1.Unmarshal(info.EncryptionAlgorithm.Parameters.FullBytes, &params); err != nil {
+    return nil, fmt.Errorf("Illegal PBES2 parameters: %v", err)
+  }
+  if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+    return nil, fmt.Errorf("Unsupported key derivation function %v (only PBKDF2 is supported)", params.KeyDerivationFunc.Algorithm)
+  }
+
+  var kdfparams pbkdf2Params
+  if _, err := stdasn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfparams); err != nil {
+    return nil, fmt.Errorf("Illegal PBKDF2 parameters: %v", err)
+  }
+  if kdfparams.IterationCount <= 0 || kdfparams.IterationCount > MaxPBKDF2Iterations {
+    return nil, fmt.Errorf("PBKDF2 iteration count %v not in range [1..%v]", kdfparams.IterationCount, MaxPBKDF2Iterations)
+  }
+  prf := "1.2.840.113549.2.7" // default is hmacWithSHA1
+  if len(kdfparams.PRF.Algorithm) > 0 {
+    prf = kdfparams.PRF.Algorithm.String()
+  }
+  h, ok := pbkdf2PRFs[prf]
+  if !ok {
+    return nil, fmt.Errorf("Unsupported PBKDF2 pseudo-random function %v", prf)
+  }
+
+  keylen, ok := pbes2Ciphers[params.EncryptionScheme.Algorithm.String()]
+  if !ok {
+    return nil, fmt.Errorf("Unsupported key encryption cipher %v (only AES-CBC is supported)", params.EncryptionScheme.Algorithm)
+  }
+  if kdfparams.KeyLength != 0 && kdfparams.KeyLength != keylen {
+    return nil, fmt.Errorf("PBKDF2 key length %v does not match the cipher", kdfparams.KeyLength)
+  }
+
+  var iv []byte
+  if _, err := stdasn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil || len(iv) != aes.BlockSize {
+    return nil, fmt.Errorf("Illegal AES-CBC initialization vector")
+  }
+
+  data := info.EncryptedData
+  if len(data) == 0 || len(data) % aes.BlockSize != 0 {
+    return nil, fmt.Errorf("Encrypted key has illegal length %v", len(data))
+  }
+
+  block, err := aes.NewCipher(pbkdf2(passphrase, kdfparams.Salt, kdfparams.IterationCount, keylen, h))
+  if err != nil {
+    return nil, err
+  }
+  decrypted := make([]byte, len(data))
+  cipher.NewCBCDecrypter(block, iv).CryptBlocks(decrypted, data)
+
+  // check and remove PKCS#7 padding
+  padlen := int(decrypted[len(decrypted)-1])
+  if padlen == 0 || padlen > aes.BlockSize || !bytes.Equal(decrypted[len(decrypted)-padlen:], bytes.Repeat([]byte{byte(padlen)}, padlen)) {
+    return nil, fmt.Errorf("Wrong passphrase")
+  }
+
+  return decrypted[:len(decrypted)-padlen], nil
+}
+
+// PBKDF2 key derivation (RFC 8018 section 5.2) with HMAC using hash function h as PRF.
+func pbkdf2(password, salt []byte, iterations, keylen int, h func() hash.Hash) []byte {
+  prf := hmac.New(h, password)
+  key := []byte{}
+  for block := 1; len(key) < keylen; block++ {
+    prf.Reset()
+    prf.Write(salt)
+    prf.Write([]byte{byte(block >> 24), byte(block >> 16), byte(block >> 8), byte(block)})
+    u := prf.Sum(nil)
+    t := append([]byte{}, u...)
+    for i := 1; i < iterations; i++ {
+      prf.Reset()
+      prf.Write(u)
+      u = prf.Sum(u[:0])
+      for k := range t {
+        t[k] ^= u[k]
+      }
+    }
+    key = append(key, t...)
+  }
+  return key[:keylen]
+}
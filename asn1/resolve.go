@@ -29,55 +29,144 @@ import (
          "math/big"
 )
 
-// Fills in d.valuedefs and d.typedefs maps for quick access via type/value name.
+// Fills in d.valuedefs, d.typedefs and d.modules maps for quick access via type/value/module name.
 func (d *Definitions) makeIndex() error {  
-  for _, c := range d.tree.children {
-    if c.nodetype == typeDefNode {
-      if _, exists := d.typedefs[c.name]; exists {
-        return NewParseError(c.src, c.pos, "Type '%v' redefined (%v: earlier definition is here)", c.name, lineCol(d.typedefs[c.name].src, d.typedefs[c.name].pos))
+  d.modules = map[string]*moduleHeader{}
+  for _, m := range d.tree.children {
+    if m.name != "" {
+      if earlier, exists := d.modules[m.name]; exists {
+        return NewParseError(m.src, m.pos, "Module '%v' redefined (%v: earlier definition is here)", m.name, lineCol(earlier.src, earlier.pos))
       }
-      if Debug {
-        fmt.Fprintf(os.Stderr, "%v: TYPE %v\n", lineCol(c.src, c.pos), c.name)
+      d.modules[m.name] = m.module
+    }
+    
+    for _, c := range m.children {
+      defs := d.valuedefs
+      what := "Value"
+      if c.nodetype == typeDefNode {
+        defs = d.typedefs
+        what = "Type"
       }
-      d.typedefs[c.name] = c
-    } else {
-      if _, exists := d.valuedefs[c.name]; exists {
-        return NewParseError(c.src, c.pos, "Value '%v' redefined (%v: earlier definition is here)", c.name, lineCol(d.valuedefs[c.name].src, d.valuedefs[c.name].pos))
+      
+      if earlier, exists := defs[c.name]; exists {
+        // Definitions of the same name in different modules are okay if both modules
+        // have a name, because then the definitions can be told apart by their qualified names.
+        if m.name == "" || earlier.module.name == "" || earlier.module == c.module {
+          return NewParseError(c.src, c.pos, "%v '%v' redefined (%v: earlier definition is here)", what, c.name, lineCol(earlier.src, earlier.pos))
+        }
+      }
+      if m.name != "" {
+        if earlier, exists := defs[m.name+"."+c.name]; exists {
+          return NewParseError(c.src, c.pos, "%v '%v' redefined (%v: earlier definition is here)", what, c.name, lineCol(earlier.src, earlier.pos))
+        }
+        defs[m.name+"."+c.name] = c
       }
+      if _, exists := defs[c.name]; !exists {
+        defs[c.name] = c
+      }
+      
       if Debug {
-        fmt.Fprintf(os.Stderr, "%v: VALUE %v\n", lineCol(c.src, c.pos), c.name)
+        fmt.Fprintf(os.Stderr, "%v: %v %v\n", lineCol(c.src, c.pos), strings.ToUpper(what), c.name)
       }
-      d.valuedefs[c.name] = c
     }
   }
   
   return nil
 }
 
+// Checks that all symbols imported from modules that are part of d are defined and
+// exported by these modules. Then replaces all type references that would be ambiguous
+// because of multiple definitions of the same name with the qualified name
+// ("Module.Type") of the definition that is visible in the referencing module (see scopedName()).
+func (d *Definitions) resolveImports() error {
+  for _, m := range d.tree.children {
+    for name, imp := range m.module.imports {
+      from, loaded := d.modules[imp.module]
+      if !loaded { 
+        continue // the definition may still be found by its unqualified name
+      }
+      _, have_type := d.typedefs[imp.module+"."+name]
+      _, have_val := d.valuedefs[imp.module+"."+name]
+      if !have_type && !have_val {
+        return NewParseError(m.src, imp.pos, "Module '%v' does not define '%v'", imp.module, name)
+      }
+      if from.exports != nil && !from.exports[name] {
+        return NewParseError(m.src, imp.pos, "Module '%v' does not export '%v'", imp.module, name)
+      }
+    }
+    
+    for _, c := range m.children {
+      d.qualifyTypeReferences(c)
+    }
+  }
+  
+  return nil
+}
+
+// Replaces t.typename (and the typenames of t's children, recursively) with the result
+// of scopedName(). Only the nodes from the ASN.1 source are processed, not those copied
+// over from referenced types.
+func (d *Definitions) qualifyTypeReferences(t *Tree) {
+  if t.typename != "" {
+    t.typename = d.scopedName(t.module, t.typename, d.typedefs)
+    return
+  }
+  for _, c := range t.children {
+    d.qualifyTypeReferences(c)
+  }
+}
+
+// Returns the key in defs (d.typedefs or d.valuedefs) for the definition that a reference
+// called name refers to when used in module m. This is the definition from m itself,
+// if there is one, or the definition imported by m, if there is one, or otherwise the definition
+// called name. The result is name unless this would refer to a different definition
+// (or name is already qualified), in which case the qualified name "Module.name" is returned.
+func (d *Definitions) scopedName(m *moduleHeader, name string, defs map[string]*Tree) string {
+  if m == nil || strings.Contains(name, ".") { return name }
+  key := name
+  if _, local := defs[m.name+"."+name]; local && m.name != "" {
+    key = m.name+"."+name
+  } else if imp, imported := m.imports[name]; imported {
+    if _, loaded := defs[imp.module+"."+name]; loaded {
+      key = imp.module+"."+name
+    }
+  }
+  if defs[key] == defs[name] { return name }
+  return key
+}
+
+// Returns the value called name as seen from the module in which t is defined (see scopedName()).
+func (d *Definitions) lookupValue(t *Tree, name string) (*Tree, bool) {
+  v, found := d.valuedefs[d.scopedName(t.module, name, d.valuedefs)]
+  return v, found
+}
+
 var universalTypes = []*Tree{
-&Tree{nodetype:typeDefNode, tags:[]byte{12,0}, source_tag:12, implicit:true, name:"UTF8String", basictype: OCTET_STRING},
-&Tree{nodetype:typeDefNode, tags:[]byte{18,0}, source_tag:18, implicit:true, name:"NumericString", basictype: OCTET_STRING},
-&Tree{nodetype:typeDefNode, tags:[]byte{19,0}, source_tag:19, implicit:true, name:"PrintableString", basictype: OCTET_STRING},
-&Tree{nodetype:typeDefNode, tags:[]byte{20,0}, source_tag:20, implicit:true, name:"TeletexString", basictype: OCTET_STRING},
-&Tree{nodetype:typeDefNode, tags:[]byte{20,0}, source_tag:20, implicit:true, name:"T61String", basictype: OCTET_STRING},
-&Tree{nodetype:typeDefNode, tags:[]byte{21,0}, source_tag:21, implicit:true, name:"VideotexString", basictype: OCTET_STRING},
-&Tree{nodetype:typeDefNode, tags:[]byte{22,0}, source_tag:22, implicit:true, name:"IA5String", basictype: OCTET_STRING},
-&Tree{nodetype:typeDefNode, tags:[]byte{23,0}, source_tag:23, implicit:true, name:"UTCTime", basictype: OCTET_STRING},
-&Tree{nodetype:typeDefNode, tags:[]byte{24,0}, source_tag:24, implicit:true, name:"GeneralizedTime", basictype: OCTET_STRING},
-&Tree{nodetype:typeDefNode, tags:[]byte{25,0}, source_tag:25, implicit:true, name:"GraphicString", basictype: OCTET_STRING},
-&Tree{nodetype:typeDefNode, tags:[]byte{26,0}, source_tag:26, implicit:true, name:"VisibleString", basictype: OCTET_STRING},
-&Tree{nodetype:typeDefNode, tags:[]byte{26,0}, source_tag:26, implicit:true, name:"ISO646String", basictype: OCTET_STRING},
-&Tree{nodetype:typeDefNode, tags:[]byte{27,0}, source_tag:27, implicit:true, name:"GeneralString", basictype: OCTET_STRING},
-&Tree{nodetype:typeDefNode, tags:[]byte{28,0}, source_tag:28, implicit:true, name:"UniversalString", basictype: OCTET_STRING},
-&Tree{nodetype:typeDefNode, tags:[]byte{30,0}, source_tag:30, implicit:true, name:"BMPString", basictype: OCTET_STRING},
+&Tree{nodetype:typeDefNode, tags:[]byte{12,0}, source_tag:12, implicit:true, name:"UTF8String", basictype: OCTET_STRING, stringTag:12},
+&Tree{nodetype:typeDefNode, tags:[]byte{18,0}, source_tag:18, implicit:true, name:"NumericString", basictype: OCTET_STRING, stringTag:18},
+&Tree{nodetype:typeDefNode, tags:[]byte{19,0}, source_tag:19, implicit:true, name:"PrintableString", basictype: OCTET_STRING, stringTag:19},
+&Tree{nodetype:typeDefNode, tags:[]byte{20,0}, source_tag:20, implicit:true, name:"TeletexString", basictype: OCTET_STRING, stringTag:20},
+&Tree{nodetype:typeDefNode, tags:[]byte{20,0}, source_tag:20, implicit:true, name:"T61String", basictype: OCTET_STRING, stringTag:20},
+&Tree{nodetype:typeDefNode, tags:[]byte{21,0}, source_tag:21, implicit:true, name:"VideotexString", basictype: OCTET_STRING, stringTag:21},
+&Tree{nodetype:typeDefNode, tags:[]byte{22,0}, source_tag:22, implicit:true, name:"IA5String", basictype: OCTET_STRING, stringTag:22},
+&Tree{nodetype:typeDefNode, tags:[]byte{23,0}, source_tag:23, implicit:true, name:"UTCTime", basictype: OCTET_STRING, stringTag:23},
+&Tree{nodetype:typeDefNode, tags:[]byte{24,0}, source_tag:24, implicit:true, name:"GeneralizedTime", basictype: OCTET_STRING, stringTag:24},
+&Tree{nodetype:typeDefNode, tags:[]byte{25,0}, source_tag:25, implicit:true, name:"GraphicString", basictype: OCTET_STRING, stringTag:25},
+&Tree{nodetype:typeDefNode, tags:[]byte{26,0}, source_tag:26, implicit:true, name:"VisibleString", basictype: OCTET_STRING, stringTag:26},
+&Tree{nodetype:typeDefNode, tags:[]byte{26,0}, source_tag:26, implicit:true, name:"ISO646String", basictype: OCTET_STRING, stringTag:26},
+&Tree{nodetype:typeDefNode, tags:[]byte{27,0}, source_tag:27, implicit:true, name:"GeneralString", basictype: OCTET_STRING, stringTag:27},
+&Tree{nodetype:typeDefNode, tags:[]byte{28,0}, source_tag:28, implicit:true, name:"UniversalString", basictype: OCTET_STRING, stringTag:28},
+&Tree{nodetype:typeDefNode, tags:[]byte{30,0}, source_tag:30, implicit:true, name:"BMPString", basictype: OCTET_STRING, stringTag:30},
 }
 
 var basicTypes = []*Tree{
 &Tree{nodetype:typeDefNode, tags:[]byte{16,0}, source_tag:16, implicit:true, name:"SEQUENCE", basictype: SEQUENCE},
 &Tree{nodetype:typeDefNode, tags:[]byte{17,0}, source_tag:17, implicit:true, name:"SET", basictype: SET},
-&Tree{nodetype:typeDefNode, tags:[]byte{4,0}, source_tag:4, implicit:true, name:"OCTET_STRING", basictype: OCTET_STRING},
-&Tree{nodetype:typeDefNode, tags:[]byte{4,0}, source_tag:4, implicit:true, name:"OCTETSTRING", basictype: OCTET_STRING},
-&Tree{nodetype:typeDefNode, tags:[]byte{4,0}, source_tag:4, implicit:true, name:"OCTET STRING", basictype: OCTET_STRING},
+&Tree{nodetype:typeDefNode, tags:[]byte{16+32,0}, source_tag:16, implicit:true, name:"SEQUENCE_OF", basictype: SEQUENCE_OF, children:[]*Tree{&Tree{nodetype:ofNode, tags:[]byte{}, source_tag:-1, basictype: ANY}}},
+&Tree{nodetype:typeDefNode, tags:[]byte{17+32,0}, source_tag:17, implicit:true, name:"SET_OF", basictype: SET_OF, children:[]*Tree{&Tree{nodetype:ofNode, tags:[]byte{}, source_tag:-1, basictype: ANY}}},
+&Tree{nodetype:typeDefNode, tags:[]byte{4,0}, source_tag:4, implicit:true, name:"OCTET_STRING", basictype: OCTET_STRING, stringTag:4},
+&Tree{nodetype:typeDefNode, tags:[]byte{4,0}, source_tag:4, implicit:true, name:"OCTETSTRING", basictype: OCTET_STRING, stringTag:4},
+&Tree{nodetype:typeDefNode, tags:[]byte{4,0}, source_tag:4, implicit:true, name:"OCTET STRING", basictype: OCTET_STRING, stringTag:4},
 &Tree{nodetype:typeDefNode, tags:[]byte{3,0}, source_tag:3, implicit:true, name:"BIT_STRING", basictype: BIT_STRING},
 &Tree{nodetype:typeDefNode, tags:[]byte{3,0}, source_tag:3, implicit:true, name:"BITSTRING", basictype: BIT_STRING},
 &Tree{nodetype:typeDefNode, tags:[]byte{3,0}, source_tag:3, implicit:true, name:"BIT STRING", basictype: BIT_STRING},
@@ -107,9 +196,61 @@ func (d *Definitions) addUniversalTypes(src string, pos int)  {
     if _, exists := d.typedefs[t.name]; !exists {
       // Create a copy with empty name to make sure its recognized as a basic type
       // by code that checks for it.
-      d.typedefs[t.name] = &Tree{nodetype:t.nodetype, tags:t.tags, source_tag:t.source_tag, implicit:t.implicit, name:"", basictype: t.basictype}
+      d.typedefs[t.name] = &Tree{nodetype:t.nodetype, tags:t.tags, source_tag:t.source_tag, implicit:t.implicit, name:"", basictype: t.basictype, children: t.children, stringTag: t.stringTag}
+    }
+  }
+}
+
+// Assigns tags to the components of all SEQUENCE, SET and CHOICE types from modules
+// with AUTOMATIC TAGS according to X.680 25.3, 27.3 and 29.3: If none of the root components
+// (i.e. the components that are not extension additions) has a tag in the ASN.1 source, the
+// untagged components are tagged [0], [1], [2],... in order, the root components
+// before the extension additions. These tags are
+// IMPLICIT, except for components that are a CHOICE or ANY, whose tags are always EXPLICIT.
+// Because the tags are stored in source_tag, calling this again has no effect.
+func (d *Definitions) resolveAutomaticTags() {
+  for _, m := range d.tree.children {
+    if m.module.automatic {
+      for _, c := range m.children {
+        automaticTags(c)
+      }
+    }
+  }
+}
+
+// Applies automatic tagging to t and the structures nested within t. Only the nodes
+// from the ASN.1 source are processed, not those copied over from referenced types.
+func automaticTags(t *Tree) {
+  if t.typename != "" { return }
+  
+  if t.basictype == SEQUENCE || t.basictype == SET || t.basictype == CHOICE {
+    tagged := false
+    for _, c := range t.children {
+      if c.source_tag >= 0 && !c.extension { tagged = true }
+    }
+    if !tagged {
+      // The root components (including those after a second extension marker) are
+      // numbered first, then the extension additions (X.680 25.3).
+      ordered := []*Tree{}
+      for _, c := range t.children {
+        if !c.extension { ordered = append(ordered, c) }
+      }
+      for _, c := range t.children {
+        if c.extension { ordered = append(ordered, c) }
+      }
+      for i, c := range ordered {
+        if c.source_tag >= 0 { continue } // a tagged extension addition keeps its tag
+        c.source_tag = 128 + sourceTagNumber(i) // context-specific
+        // A tag on an untagged CHOICE or ANY is always EXPLICIT (X.680 31.2.7). If c refers
+        // to a CHOICE or ANY type by name, this is taken care of by fillin().
+        c.implicit = c.typename != "" || (c.basictype != CHOICE && c.basictype != ANY)
+      }
     }
   }
+  
+  for _, c := range t.children {
+    automaticTags(c)
+  }
 }
 
 // After this, typeDefNodes are fully resolved, i.e. their basictype, children and namedints fields
@@ -118,13 +259,13 @@ func (d *Definitions) addUniversalTypes(src string, pos int)  {
 // NOTE: children of typeDefNodes (i.e. ofNodes and fieldNodes) are not yet resolved.
 func (d *Definitions) resolveTypes() error {  
   // Tracks which types have been resolved
-  resolved := map[string]bool{}
+  resolved := map[*Tree]bool{}
   
   // First pass: Handle types that are defined purely by means of basic ASN.1 types
   for _, c := range d.typedefs {
     if c.typename == "" { 
       c.tags = generateTags(c.basictype, c.source_tag, c.implicit)
-      resolved[c.name] = true
+      resolved[c] = true
       if Debug {
         fmt.Fprintf(os.Stderr, "%v: BASIC %v\n", lineCol(c.src, c.pos), c.name)
       }
@@ -138,12 +279,10 @@ func (d *Definitions) resolveTypes() error {
   for newinfo {
     newinfo = false
     for _, c := range d.typedefs {
-      if !resolved[c.name] && resolved[c.typename] {
-        t := d.typedefs[c.typename]
-        
+      if t := d.typedefs[c.typename]; !resolved[c] && resolved[t] {
         fillin(c,t)
         
-        resolved[c.name] = true
+        resolved[c] = true
         newinfo = true
         if Debug {
           fmt.Fprintf(os.Stderr, "%v: RESOLVED %v -> %v\n", lineCol(c.src, c.pos), c.name, t.name)
@@ -155,7 +294,7 @@ func (d *Definitions) resolveTypes() error {
   // Check for a type that could not be resolved due to a reference to an unknown type and
   // return an error for it.
   for _, c := range d.typedefs {
-    if !resolved[c.name] {
+    if !resolved[c] {
       if _, ok := d.typedefs[c.typename]; !ok {
         return NewParseError(c.src, c.pos, "Definition of type '%v' refers to unknown type '%v'", c.name, c.typename)
       }
@@ -167,7 +306,7 @@ func (d *Definitions) resolveTypes() error {
   // WE NEED TO DIAGNOSE UNKNOWN TYPE ERRORS FIRST, OR WE MAY PRODUCE INCORRECT ERRORS
   // ABOUT DEFINITION LOOPS!
   for _, c := range d.typedefs {
-    if !resolved[c.name] {
+    if !resolved[c] {
       return NewParseError(c.src, c.pos, "Type definition loop '%v' -> '%v' -> ... -> '%v'", c.name, c.typename, c.name)
     }
   }
@@ -175,6 +314,16 @@ func (d *Definitions) resolveTypes() error {
   return nil 
 }
 
+// Returns the tag number of sourcetag (see Tree.source_tag).
+func tagNumber(sourcetag int) int {
+  return (sourcetag & 63) + ((sourcetag >> 8) << 6)
+}
+
+// Returns the Tree.source_tag for tag number num with class bits 0.
+func sourceTagNumber(num int) int {
+  return (num & 63) + ((num >> 6) << 8)
+}
+
 func generateTags(basictype int, sourcetag int, implicit bool) []byte {
   var basictype_constructed byte = 0
   if basictype == SEQUENCE || basictype == SEQUENCE_OF || basictype == SET || basictype == SET_OF {
@@ -183,11 +332,18 @@ func generateTags(basictype int, sourcetag int, implicit bool) []byte {
   
   tags := []byte{}
   if sourcetag >= 0 {
-    tagnum := sourcetag & 63
+    tagnum := tagNumber(sourcetag)
     if tagnum >= 31 {
-      // Since we only support tags <= 63 we never need more than additional 1 byte
       tags = append(tags, byte((sourcetag & (128+64)) + 31)|basictype_constructed)
-      tags = append(tags, byte(tagnum))
+      // base 128 with bit 8 set on all but the last byte
+      start := len(tags)
+      for ; tagnum > 0; tagnum >>= 7 {
+        tags = append(tags, byte(tagnum & 127) | 128)
+      }
+      for i, k := start, len(tags)-1; i < k; i, k = i+1, k-1 {
+        tags[i], tags[k] = tags[k], tags[i]
+      }
+      tags[len(tags)-1] &= 127
     } else {
       tags = append(tags, byte(sourcetag)|basictype_constructed)
     }
@@ -223,14 +379,14 @@ func fillin(dest,src *Tree) {
   dest.basictype = src.basictype
   dest.children = src.children
   dest.namedints = src.namedints
+  dest.stringTag = src.stringTag
+  dest.extensionMarkers = src.extensionMarkers
   if dest.source_tag >= 0 {
     dest.tags = generateTags(dest.basictype, dest.source_tag, true)
     idx := 0 // how many bytes from src.tags to skip
     if dest.implicit { // if dest's tag is IMPLICIT, it replaces the first tag of src
       if len(src.tags) > 0 {
-        // find the 0 following the tag
-        for src.tags[idx] != 0 { idx++ }
-        idx++ // skip the 0, too because dest.tags has its own 0
+        idx = tagLength(src.tags) + 1 // skip the 0 following the tag, too, because dest.tags has its own 0
         dest.tags[0] |= src.tags[0] & 32 // transfer constructed flag 
       }
     } else { // if EXPLICIT
@@ -318,7 +474,7 @@ func (d *Definitions) parseValue(v *Tree) error {
       return nil
     }
     
-    if ref_v, found := d.valuedefs[val]; found {
+    if ref_v, found := d.lookupValue(v, val); found {
       if v.basictype == OBJECT_IDENTIFIER {
         v.value = []interface{}{ref_v}
       } else {
@@ -374,7 +530,7 @@ func (d *Definitions) parseValue(v *Tree) error {
           var oida []interface{}
         
           if tokValueReference.Regex.MatchString(parts[0]) {
-            if ref_v, found := d.valuedefs[parts[0]]; found {
+            if ref_v, found := d.lookupValue(v, parts[0]); found {
               oida = append(oida, ref_v)
             } else {
               return unknownValueReference(v, parts[0])
@@ -520,6 +676,10 @@ func (d *Definitions) resolveFields(t *Tree) error {
       t.tags = generateTags(t.basictype, t.source_tag, t.implicit)
     }
     
+    if err := d.resolveConstraints(t, d.typedefs[t.typename]); err != nil {
+      return err
+    }
+    
     // resolve DEFAULT value if present
     if t.value != nil {
       err := d.parseValue(t)
@@ -0,0 +1,481 @@
+/*
+Copyright (c) 2015 Matthias S. Benkmann
+
+This program is free software; you can redistribute it and/or
+modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation; version 3
+of the License (ONLY this version).
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+*/
+
+/*
+  This file contains the code for converting keys to and from
+  the binary formats used by OpenSSH (see doc/openssh-private-key-format.txt
+  and RFC 4253).
+*/
+
+package asn1
+
+import (
+         "fmt"
+         "bytes"
+         "math/big"
+         "crypto/rand"
+         "crypto"
+         "crypto/rsa"
+         "crypto/ecdsa"
+         "crypto/ed25519"
+         "crypto/elliptic"
+         "crypto/sha256"
+         "crypto/sha512"
+         "sort"
+         stdasn1 "encoding/asn1"
+       )
+
+// Maps the names of the elliptic curves supported by OpenSSH to the
+// identifiers used in key type names and key encodings.
+var sshCurveNames = map[string]string{"P-256":"nistp256", "P-384":"nistp384", "P-521":"nistp521"}
+
+// Returns the name OpenSSH uses for the type of key, e.g. "ssh-ed25519".
+// key must be an *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey.
+func SSHKeyType(key crypto.PublicKey) (string, error) {
+  switch k := key.(type) {
+    case *rsa.PublicKey: return "ssh-rsa", nil
+    case ed25519.PublicKey: return "ssh-ed25519", nil
+    case *ecdsa.PublicKey:
+        if curve, ok := sshCurveNames[k.Curve.Params().Name]; ok {
+          return "ecdsa-sha2-" + curve, nil
+        }
+        return "", fmt.Errorf("Elliptic curve %v is not supported by OpenSSH", k.Curve.Params().Name)
+  }
+  return "", fmt.Errorf("Key type %T is not supported by OpenSSH", key)
+}
+
+// Returns the public key blob for key as used by OpenSSH in authorized_keys
+// files (after base64 decoding) and the SSH protocol (RFC 4253, 6.6).
+// key must be an *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey.
+func MarshalSSHPublicKey(key crypto.PublicKey) ([]byte, error) {
+  keytype, err := SSHKeyType(key)
+  if err != nil {
+    return nil, err
+  }
+
+  b := []byte{}
+  sshString(&b, []byte(keytype))
+  switch k := key.(type) {
+    case *rsa.PublicKey: sshMpint(&b, big.NewInt(int64(k.E)))
+                         sshMpint(&b, k.N)
+    case ed25519.PublicKey: sshString(&b, k)
+    case *ecdsa.PublicKey: sshString(&b, []byte(sshCurveNames[k.Curve.Params().Name]))
+                           sshString(&b, elliptic.Marshal(k.Curve, k.X, k.Y))
+  }
+  return b, nil
+}
+
+// Values for the type field of an SSHCertificate.
+const (
+  SSHUserCert = 1
+  SSHHostCert = 2
+)
+
+// An OpenSSH certificate (see PROTOCOL.certkeys in the OpenSSH sources).
+type SSHCertificate struct {
+  Nonce []byte // if empty, Sign() fills in 32 random bytes
+  Key crypto.PublicKey // the certified key
+  Serial uint64
+  Type uint32 // SSHUserCert or SSHHostCert
+  KeyId string
+  ValidPrincipals []string // empty means valid for any principal
+  ValidAfter uint64 // seconds since the epoch
+  ValidBefore uint64 // seconds since the epoch; 0xFFFFFFFFFFFFFFFF means forever
+  CriticalOptions map[string]string // values are the option data, e.g. the command for "force-command"
+  Extensions map[string]string // values are usually "", e.g. for "permit-pty"
+  SignatureKey crypto.PublicKey // filled in by Sign()
+  Signature []byte // filled in by Sign()
+}
+
+// Returns the name OpenSSH uses for the type of certificate for key,
+// e.g. "ssh-ed25519-cert-v01@openssh.com".
+func SSHCertType(key crypto.PublicKey) (string, error) {
+  keytype, err := SSHKeyType(key)
+  if err != nil {
+    return "", err
+  }
+  return keytype + "-cert-v01@openssh.com", nil
+}
+
+// Fills in Nonce (if empty), SignatureKey and Signature using the CA key signer.
+// RSA signatures use "rsa-sha2-512".
+func (c *SSHCertificate) Sign(signer crypto.Signer) error {
+  if len(c.Nonce) == 0 {
+    c.Nonce = make([]byte, 32)
+    if _, err := rand.Read(c.Nonce); err != nil {
+      return err
+    }
+  }
+  c.SignatureKey = signer.Public()
+  c.Signature = nil
+  tbs, err := c.marshal()
+  if err != nil {
+    return err
+  }
+  c.Signature, err = sshSign(signer, tbs)
+  return err
+}
+
+// Returns the certificate blob as used (after base64 encoding) in
+// *-cert.pub files. Sign() must have been called before.
+func (c *SSHCertificate) Marshal() ([]byte, error) {
+  if c.Signature == nil {
+    return nil, fmt.Errorf("SSH certificate has not been signed")
+  }
+  return c.marshal()
+}
+
+// Returns the encoding of c, without the signature if c.Signature is nil.
+func (c *SSHCertificate) marshal() ([]byte, error) {
+  certtype, err := SSHCertType(c.Key)
+  if err != nil {
+    return nil, err
+  }
+  pub, err := MarshalSSHPublicKey(c.Key)
+  if err != nil {
+    return nil, err
+  }
+  cakey, err := MarshalSSHPublicKey(c.SignatureKey)
+  if err != nil {
+    return nil, err
+  }
+
+  b := []byte{}
+  sshString(&b, []byte(certtype))
+  sshString(&b, c.Nonce)
+  r := &sshReader{data: pub}
+  r.String() // skip key type, the cert contains only the key-specific fields
+  b = append(b, r.data...)
+  sshUint64(&b, c.Serial)
+  sshUint32(&b, c.Type)
+  sshString(&b, []byte(c.KeyId))
+  principals := []byte{}
+  for _, p := range c.ValidPrincipals {
+    sshString(&principals, []byte(p))
+  }
+  sshString(&b, principals)
+  sshUint64(&b, c.ValidAfter)
+  sshUint64(&b, c.ValidBefore)
+  sshString(&b, sshOptions(c.CriticalOptions))
+  sshString(&b, sshOptions(c.Extensions))
+  sshString(&b, []byte{}) // reserved
+  sshString(&b, cakey)
+  if c.Signature != nil {
+    sshString(&b, c.Signature)
+  }
+  return b, nil
+}
+
+// Returns the encoding of critical options or extensions. Names are sorted as
+// required by OpenSSH. Non-empty values are wrapped in an additional string.
+func sshOptions(options map[string]string) []byte {
+  names := []string{}
+  for name := range options {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+  b := []byte{}
+  for _, name := range names {
+    sshString(&b, []byte(name))
+    data := []byte{}
+    if options[name] != "" {
+      sshString(&data, []byte(options[name]))
+    }
+    sshString(&b, data)
+  }
+  return b
+}
+
+// Signs data with signer and returns the SSH signature blob (RFC 4253, 6.6).
+func sshSign(signer crypto.Signer, data []byte) ([]byte, error) {
+  var format string
+  var hash crypto.Hash
+  switch k := signer.Public().(type) {
+    case ed25519.PublicKey: format = "ssh-ed25519"
+    case *rsa.PublicKey: format = "rsa-sha2-512"
+                         hash = crypto.SHA512
+    case *ecdsa.PublicKey:
+        format, _ = SSHKeyType(k)
+        switch k.Curve.Params().BitSize {
+          case 256: hash = crypto.SHA256
+          case 384: hash = crypto.SHA384
+          default:  hash = crypto.SHA512
+        }
+    default: return nil, fmt.Errorf("Key type %T is not supported by OpenSSH", k)
+  }
+
+  digest := data
+  switch hash {
+    case crypto.SHA256: d := sha256.Sum256(data); digest = d[:]
+    case crypto.SHA384: d := sha512.Sum384(data); digest = d[:]
+    case crypto.SHA512: d := sha512.Sum512(data); digest = d[:]
+  }
+  sig, err := signer.Sign(rand.Reader, digest, hash)
+  if err != nil {
+    return nil, err
+  }
+
+  if _, ok := signer.Public().(*ecdsa.PublicKey); ok {
+    var rs struct { R, S *big.Int }
+    if _, err := stdasn1.Unmarshal(sig, &rs); err != nil {
+      return nil, err
+    }
+    sig = []byte{}
+    sshMpint(&sig, rs.R)
+    sshMpint(&sig, rs.S)
+  }
+
+  b := []byte{}
+  sshString(&b, []byte(format))
+  sshString(&b, sig)
+  return b, nil
+}
+
+// Appends n as a big endian uint64 to b.
+func sshUint64(b *[]byte, n uint64) {
+  sshUint32(b, uint32(n >> 32))
+  sshUint32(b, uint32(n))
+}
+
+// Appends n as a big endian uint32 to b.
+func sshUint32(b *[]byte, n uint32) {
+  *b = append(*b, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n))
+}
+
+// Appends s as SSH string (i.e. preceded by its length) to b.
+func sshString(b *[]byte, s []byte) {
+  sshUint32(b, uint32(len(s)))
+  *b = append(*b, s...)
+}
+
+// Appends non-negative n as SSH mpint to b.
+func sshMpint(b *[]byte, n *big.Int) {
+  mag := n.Bytes()
+  if len(mag) > 0 && mag[0] & 0x80 != 0 { // prevent misinterpretation as negative number
+    mag = append([]byte{0}, mag...)
+  }
+  sshString(b, mag)
+}
+
+// Magic bytes at the start of an OpenSSH private key file (after base64 decoding).
+const openSSHKeyMagic = "openssh-key-v1\x00"
+
+// Returns the private key contained in data, which is the binary "openssh-key-v1"
+// format (i.e. the base64 decoded contents of an "OPENSSH PRIVATE KEY" PEM block,
+// see doc/openssh-private-key-format.txt). If the file contains multiple keys, the
+// 1st one is returned. Encrypted keys are not supported.
+func ParseOpenSSHPrivateKey(data []byte) (crypto.Signer, error) {
+  if !bytes.HasPrefix(data, []byte(openSSHKeyMagic)) {
+    return nil, fmt.Errorf("Not an OpenSSH private key")
+  }
+  r := &sshReader{data: data[len(openSSHKeyMagic):]}
+  ciphername := string(r.String())
+  kdfname := string(r.String())
+  r.String() // kdfoptions
+  n := r.Uint32()
+  for i := uint32(0); i < n; i++ {
+    r.String() // public keys are contained in the private section, too
+  }
+  private := r.String()
+  if r.err != nil {
+    return nil, r.err
+  }
+  if ciphername != "none" || kdfname != "none" {
+    return nil, fmt.Errorf("Encrypted OpenSSH private keys are not supported (cipher: %v, kdf: %v)", ciphername, kdfname)
+  }
+  if n == 0 {
+    return nil, fmt.Errorf("OpenSSH private key file contains no key")
+  }
+
+  r = &sshReader{data: private}
+  if r.Uint32() != r.Uint32() {
+    return nil, fmt.Errorf("OpenSSH private key is corrupt (checkint mismatch)")
+  }
+  keytype := string(r.String())
+  var key crypto.Signer
+  switch keytype {
+    case "ssh-ed25519":
+        pub := r.String() // public key is contained in the private key, too
+        priv := r.String()
+        if r.err == nil {
+          if len(priv) != ed25519.PrivateKeySize {
+            return nil, fmt.Errorf("OpenSSH ed25519 private key has wrong length %v", len(priv))
+          }
+          // priv is seed || public key
+          derived := ed25519.NewKeyFromSeed(priv[:ed25519.SeedSize])
+          if !bytes.Equal(pub, priv[ed25519.SeedSize:]) || !bytes.Equal(derived, priv) {
+            return nil, fmt.Errorf("OpenSSH ed25519 private key does not match its public key")
+          }
+        }
+        key = ed25519.PrivateKey(priv)
+
+    case "ssh-rsa":
+        k := &rsa.PrivateKey{}
+        k.N = r.Mpint()
+        e := r.Mpint()
+        k.D = r.Mpint()
+        r.Mpint() // iqmp is computed by Precompute()
+        k.Primes = []*big.Int{r.Mpint(), r.Mpint()}
+        if r.err == nil {
+          if !e.IsInt64() || e.Int64() > 1 << 31 - 1 {
+            return nil, fmt.Errorf("OpenSSH RSA private key has unsupported public exponent %v", e)
+          }
+          k.E = int(e.Int64())
+          if err := k.Validate(); err != nil {
+            return nil, err
+          }
+          k.Precompute()
+        }
+        key = k
+
+    case "ecdsa-sha2-nistp256", "ecdsa-sha2-nistp384", "ecdsa-sha2-nistp521":
+        var curve elliptic.Curve
+        curvename := string(r.String())
+        switch curvename {
+          case "nistp256": curve = elliptic.P256()
+          case "nistp384": curve = elliptic.P384()
+          case "nistp521": curve = elliptic.P521()
+          default: if r.err == nil {
+                     return nil, fmt.Errorf("OpenSSH ECDSA private key has unsupported curve")
+                   }
+        }
+        if r.err == nil && keytype != "ecdsa-sha2-" + curvename {
+          return nil, fmt.Errorf("OpenSSH ECDSA private key has curve %v that does not match key type %v", curvename, keytype)
+        }
+        k := &ecdsa.PrivateKey{}
+        k.Curve = curve
+        pub := r.String()
+        k.D = r.Mpint()
+        if r.err == nil {
+          k.X, k.Y = elliptic.Unmarshal(curve, pub)
+          if k.X == nil {
+            return nil, fmt.Errorf("OpenSSH ECDSA private key has illegal public key")
+          }
+          x, y := curve.ScalarBaseMult(k.D.Bytes())
+          if k.D.Sign() == 0 || x.Cmp(k.X) != 0 || y.Cmp(k.Y) != 0 {
+            return nil, fmt.Errorf("OpenSSH ECDSA private key does not match its public key")
+          }
+        }
+        key = k
+
+    default:
+        if r.err == nil {
+          return nil, fmt.Errorf("Unsupported OpenSSH key type \"%v\"", keytype)
+        }
+  }
+  r.String() // comment
+  if r.err != nil {
+    return nil, r.err
+  }
+  // If there is only 1 key, it is followed by the padding 1,2,3,...
+  // Otherwise the remaining keys come first, which are not parsed.
+  if n == 1 {
+    for i, b := range r.data {
+      if int(b) != i+1 {
+        return nil, fmt.Errorf("OpenSSH private key is corrupt (illegal padding)")
+      }
+    }
+  }
+  return key, nil
+}
+
+// Returns key with the given comment in the binary "openssh-key-v1" format
+// (unencrypted). The result needs to be base64 encoded and put into an
+// "OPENSSH PRIVATE KEY" PEM block.
+func MarshalOpenSSHPrivateKey(key crypto.Signer, comment string) ([]byte, error) {
+  pub, err := MarshalSSHPublicKey(key.Public())
+  if err != nil {
+    return nil, err
+  }
+  keytype, _ := SSHKeyType(key.Public()) // no error possible because MarshalSSHPublicKey() succeeded
+
+  checkint := make([]byte, 4)
+  if _, err := rand.Read(checkint); err != nil {
+    return nil, err
+  }
+  private := append(append([]byte{}, checkint...), checkint...)
+  sshString(&private, []byte(keytype))
+  switch k := key.(type) {
+    case ed25519.PrivateKey:
+        sshString(&private, k.Public().(ed25519.PublicKey))
+        sshString(&private, k)
+    case *rsa.PrivateKey:
+        if len(k.Primes) != 2 {
+          return nil, fmt.Errorf("OpenSSH does not support multi-prime RSA keys")
+        }
+        sshMpint(&private, k.N)
+        sshMpint(&private, big.NewInt(int64(k.E)))
+        sshMpint(&private, k.D)
+        sshMpint(&private, new(big.Int).ModInverse(k.Primes[1], k.Primes[0]))
+        sshMpint(&private, k.Primes[0])
+        sshMpint(&private, k.Primes[1])
+    case *ecdsa.PrivateKey:
+        sshString(&private, []byte(sshCurveNames[k.Curve.Params().Name]))
+        sshString(&private, elliptic.Marshal(k.Curve, k.X, k.Y))
+        sshMpint(&private, k.D)
+  }
+  sshString(&private, []byte(comment))
+  for i := byte(1); len(private) % 8 != 0; i++ { // pad to cipher block size (8 for "none")
+    private = append(private, i)
+  }
+
+  b := []byte(openSSHKeyMagic)
+  sshString(&b, []byte("none"))
+  sshString(&b, []byte("none"))
+  sshString(&b, []byte{})
+  sshUint32(&b, 1)
+  sshString(&b, pub)
+  sshString(&b, private)
+  return b, nil
+}
+
+// Reads the data types of the SSH wire format from data. After the 1st error
+// all reads return zero values and err is set.
+type sshReader struct {
+  data []byte
+  err error
+}
+
+func (r *sshReader) Uint32() uint32 {
+  if r.err == nil && len(r.data) < 4 {
+    r.err = fmt.Errorf("Unexpected end of OpenSSH data")
+  }
+  if r.err != nil {
+    return 0
+  }
+  n := uint32(r.data[0]) << 24 | uint32(r.data[1]) << 16 | uint32(r.data[2]) << 8 | uint32(r.data[3])
+  r.data = r.data[4:]
+  return n
+}
+
+func (r *sshReader) String() []byte {
+  n := r.Uint32()
+  if r.err == nil && uint32(len(r.data)) < n {
+    r.err = fmt.Errorf("Unexpected end of OpenSSH data")
+  }
+  if r.err != nil {
+    return nil
+  }
+  s := r.data[0:n]
+  r.data = r.data[n:]
+  return s
+}
+
+func (r *sshReader) Mpint() *big.Int {
+  s := r.String()
+  if r.err == nil && len(s) > 0 && s[0] & 0x80 != 0 {
+    r.err = fmt.Errorf("Unexpected negative number in OpenSSH data")
+  }
+  return new(big.Int).SetBytes(s)
+}
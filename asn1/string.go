@@ -134,12 +134,56 @@ func stringInstance(s *[]string, t *Tree) {
 }
 
 func stringDEFINITIONS(s *[]string, t *Tree) {
-  if t.implicit {
+  for i, m := range t.children {
+    if i > 0 { *s = append(*s, "\n") }
+    stringModule(s, m)
+  }
+}
+
+func stringModule(s *[]string, t *Tree) {
+  if t.name != "" {
+    *s = append(*s, t.name, " ")
+    if t.module.oid != "" {
+      *s = append(*s, t.module.oid, " ")
+    }
+  }
+  
+  if t.module.automatic {
+    *s = append(*s, "DEFINITIONS AUTOMATIC TAGS ::=\n\nBEGIN\n\n")
+  } else if t.implicit {
     *s = append(*s, "DEFINITIONS IMPLICIT TAGS ::=\n\nBEGIN\n\n")
   } else {
     *s = append(*s, "DEFINITIONS EXPLICIT TAGS ::=\n\nBEGIN\n\n")
   }
   
+  if t.module.exports != nil {
+    exports := []string{}
+    for name := range t.module.exports {
+      exports = append(exports, name)
+    }
+    sort.Strings(exports)
+    *s = append(*s, "EXPORTS ", strings.Join(exports, ", "), ";\n\n")
+  }
+  
+  if t.module.imports != nil {
+    // group the symbols by the module they are imported from
+    from := map[string][]string{}
+    modules := []string{}
+    for name, imp := range t.module.imports {
+      if from[imp.module] == nil {
+        modules = append(modules, imp.module)
+      }
+      from[imp.module] = append(from[imp.module], name)
+    }
+    sort.Strings(modules)
+    *s = append(*s, "IMPORTS")
+    for _, m := range modules {
+      sort.Strings(from[m])
+      *s = append(*s, "\n  ", strings.Join(from[m], ", "), " FROM ", m)
+    }
+    *s = append(*s, ";\n\n")
+  }
+  
   for _, c := range t.children {
     if c.nodetype == typeDefNode { 
       stringTypeDefinition(s, c)
@@ -164,7 +208,7 @@ func stringType(indent string, s *[]string, t *Tree) {
     *s = append(*s, "[")
     *s = append(*s, TagClass[t.source_tag & (128+64)])
     *s = append(*s, "")
-    *s = append(*s, strconv.Itoa(t.source_tag & 63))
+    *s = append(*s, strconv.Itoa(tagNumber(t.source_tag)))
     *s = append(*s, "]")
     if t.implicit {
       *s = append(*s, " IMPLICIT ")
@@ -213,16 +257,27 @@ func stringValueDefinition(s *[]string, t *Tree) {
 
 func stringStructure(indent string, s *[]string, t *Tree) {
   *s = append(*s, "{\n")
-  for i, c := range t.children {
+  comma := false
+  markers := 0
+  marker := func() {
+    if comma { *s = append(*s, ",\n") } else { comma = true }
+    *s = append(*s, indent+"    ...")
+    markers++
+  }
+  for _, c := range t.children {
+    if (c.extension && markers == 0) || (!c.extension && markers == 1) {
+      marker()
+    }
+    if comma { *s = append(*s, ",\n") } else { comma = true }
     *s = append(*s, indent+"    ")
     *s = append(*s, c.name)
     *s = append(*s, " ")
     stringType(indent+"    ", s, c)
-    if i < len(t.children)-1 {
-      *s = append(*s, ",")
-    }
-    *s = append(*s, "\n")
   }
+  for markers < t.extensionMarkers {
+    marker()
+  }
+  if comma { *s = append(*s, "\n") }
   *s = append(*s, indent)
   *s = append(*s, "}")
 }
@@ -242,11 +297,14 @@ func stringLabelledInts(indent string, s *[]string, t *Tree) {
     *s = append(*s, " (")
     *s = append(*s, fmt.Sprintf("%v", values[i]))
     *s = append(*s, ")")
-    if i < len(values)-1 {
+    if i < len(values)-1 || t.extensionMarkers > 0 {
       *s = append(*s, ",")
     }
     *s = append(*s, "\n")
   }
+  if t.extensionMarkers > 0 {
+    *s = append(*s, indent+"    ...\n")
+  }
   *s = append(*s, indent)
   *s = append(*s, "}")
 }
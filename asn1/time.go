@@ -0,0 +1,201 @@
+/*
+Copyright (c) 2015 Matthias S. Benkmann
+
+This program is free software; you can redistribute it and/or
+modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation; version 3
+of the License (ONLY this version).
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+*/
+
+/*
+  This file contains the code for converting between Go's time.Time and
+  the DER encodings of UTCTime and GeneralizedTime (X.690 section 11.7 and 11.8,
+  RFC 5280 section 4.1.2.5).
+*/
+
+package asn1
+
+import (
+         "fmt"
+         "time"
+         "regexp"
+         "strings"
+         "strconv"
+       )
+
+// Universal tags of the time types.
+const (
+  UTCTimeTag = 23
+  GeneralizedTimeTag = 24
+)
+
+var derUTCTime = regexp.MustCompile(`^[0-9]{12}Z$`)
+var derGeneralizedTime = regexp.MustCompile(`^[0-9]{14}(\.[0-9]*[1-9])?Z$`)
+var relativeTime = regexp.MustCompile(`^now((?:[+-][0-9]+[smhdw])*)$`)
+var relativeTimeTerm = regexp.MustCompile(`([+-][0-9]+)([smhdw])`)
+
+var timeUnits = map[string]time.Duration{
+  "s": time.Second,
+  "m": time.Minute,
+  "h": time.Hour,
+  "d": 24*time.Hour,
+  "w": 7*24*time.Hour,
+}
+
+// Returns UTCTimeTag or GeneralizedTimeTag if t is of the respective type
+// (no matter how it is tagged). Otherwise returns 0.
+func timeTag(t *Tree) int {
+  switch t.stringTag {
+    case UTCTimeTag, GeneralizedTimeTag: return t.stringTag
+  }
+  return 0
+}
+
+// Returns the tag of the time type RFC 5280 requires for encoding t, i.e.
+// UTCTimeTag for the years 1950 through 2049 and GeneralizedTimeTag otherwise.
+func TimeTagFor(t time.Time) int {
+  y := t.UTC().Year()
+  if y >= 1950 && y < 2050 { return UTCTimeTag }
+  return GeneralizedTimeTag
+}
+
+// Returns an Instance of UTCTime (if tag == UTCTimeTag) or GeneralizedTime
+// (if tag == GeneralizedTimeTag) for t. If tag == 0, the type is chosen with TimeTagFor().
+func TimeInstance(t time.Time, tag int) (*Instance, error) {
+  if tag == 0 { tag = TimeTagFor(t) }
+  if tag != UTCTimeTag && tag != GeneralizedTimeTag {
+    return nil, fmt.Errorf("Tag %v is not a time type", tag)
+  }
+  typ := &Tree{nodetype:typeDefNode, tags:[]byte{byte(tag),0}, source_tag:tag, implicit:true, typename:UniversalTagName[tag], basictype:OCTET_STRING, stringTag:tag}
+  return typ.instantiate(t, &pathNode{})
+}
+
+// Returns the DER encoding of t as UTCTime, i.e. YYMMDDHHMMSSZ.
+// Returns an error if t is outside of the years 1950 through 2049 or has fractional seconds.
+func UTCTime(t time.Time) (string, error) {
+  t = t.UTC()
+  if t.Year() < 1950 || t.Year() >= 2050 {
+    return "", fmt.Errorf("UTCTime can only represent the years 1950 through 2049: %v", t.Format(time.RFC3339Nano))
+  }
+  if t.Nanosecond() != 0 {
+    return "", fmt.Errorf("UTCTime cannot represent fractional seconds: %v", t.Format(time.RFC3339Nano))
+  }
+  return t.Format("060102150405Z"), nil
+}
+
+// Returns the DER encoding of t as GeneralizedTime, i.e. YYYYMMDDHHMMSS[.fff]Z with
+// the fractional seconds (if any) without trailing zeros.
+// Returns an error if t is outside of the years 0 through 9999.
+func GeneralizedTime(t time.Time) (string, error) {
+  t = t.UTC()
+  if t.Year() < 0 || t.Year() > 9999 {
+    return "", fmt.Errorf("GeneralizedTime can only represent the years 0 through 9999: %v", t.Format(time.RFC3339Nano))
+  }
+  // ".999999999" drops trailing zeros and the "." if the fraction is 0
+  return t.Format("20060102150405.999999999Z"), nil
+}
+
+// Parses s which must be a DER encoded UTCTime (if tag == UTCTimeTag) or
+// GeneralizedTime (if tag == GeneralizedTimeTag).
+func ParseDERTime(tag int, s string) (time.Time, error) {
+  var year int
+  orig := s
+  switch tag {
+    case UTCTimeTag:
+      if !derUTCTime.MatchString(s) {
+        return time.Time{}, fmt.Errorf("UTCTime must have the form YYMMDDHHMMSSZ: %v", s)
+      }
+      year, _ = strconv.Atoi(s[0:2])
+      if year < 50 { year += 2000 } else { year += 1900 }
+      s = s[2:]
+    case GeneralizedTimeTag:
+      if !derGeneralizedTime.MatchString(s) {
+        return time.Time{}, fmt.Errorf("GeneralizedTime must have the form YYYYMMDDHHMMSS[.fff]Z without trailing zeros in the fraction: %v", s)
+      }
+      year, _ = strconv.Atoi(s[0:4])
+      s = s[4:]
+    default:
+      return time.Time{}, fmt.Errorf("Tag %v is not a time type", tag)
+  }
+
+  var f [5]int
+  for i := range f {
+    f[i], _ = strconv.Atoi(s[2*i:2*i+2])
+  }
+  nsec := 0
+  if s[10] == '.' {
+    frac := (s[11:len(s)-1] + "000000000")
+    if len(frac) > 18 {
+      return time.Time{}, fmt.Errorf("More than 9 fractional digits are not supported: %v", orig)
+    }
+    nsec, _ = strconv.Atoi(frac[0:9])
+  }
+
+  t := time.Date(year, time.Month(f[0]), f[1], f[2], f[3], f[4], nsec, time.UTC)
+  if t.Year() != year || int(t.Month()) != f[0] || t.Day() != f[1] || t.Hour() != f[2] || t.Minute() != f[3] || t.Second() != f[4] {
+    return time.Time{}, fmt.Errorf("Illegal date/time: %v", orig)
+  }
+  return t, nil
+}
+
+// Parses s which may be either
+//   * an RFC 3339 date/time such as "2015-11-01T00:00:00Z" or "2015-11-01T01:00:00+01:00"
+//   * "now", optionally followed by one or more offsets, each consisting of
+//     "+" or "-", a number and one of the units "s" (seconds), "m" (minutes),
+//     "h" (hours), "d" (days) and "w" (weeks), e.g. "now+365d" or "now-1h+30s".
+//     The current time is truncated to full seconds.
+func ParseTime(s string) (time.Time, error) {
+  if m := relativeTime.FindStringSubmatch(s); m != nil {
+    t := time.Now().UTC().Truncate(time.Second)
+    for _, term := range relativeTimeTerm.FindAllStringSubmatch(m[1], -1) {
+      n, err := strconv.ParseInt(term[1], 10, 64)
+      if err != nil || n > int64(1<<62) / int64(timeUnits[term[2]]) || n < -int64(1<<62) / int64(timeUnits[term[2]]) {
+        return time.Time{}, fmt.Errorf("Time offset out of range: %v", s)
+      }
+      t = t.Add(time.Duration(n) * timeUnits[term[2]])
+    }
+    return t, nil
+  }
+
+  t, err := time.Parse(time.RFC3339Nano, s)
+  if err != nil {
+    return time.Time{}, fmt.Errorf("Not an RFC 3339 date/time or \"now[+-Nunit]\" expression: %v", s)
+  }
+  return t.UTC(), nil
+}
+
+// Returns the DER encoding of data as the time type identified by tag.
+// data may be a time.Time or a string. If the string looks like a DER encoded
+// time (i.e. it contains no "-", ":" or "T" and does not start with "now"), it is
+// checked for conformance to DER and returned unchanged. Otherwise
+// it is parsed with ParseTime().
+func encodeTime(tag int, data interface{}) ([]byte, error) {
+  var t time.Time
+  switch data := data.(type) {
+    case time.Time: t = data
+    case string:
+      if !strings.HasPrefix(data, "now") && !strings.ContainsAny(data, "-:Tt") {
+        _, err := ParseDERTime(tag, data)
+        if err != nil { return nil, err }
+        return []byte(data), nil
+      }
+      var err error
+      t, err = ParseTime(data)
+      if err != nil { return nil, err }
+    default: panic("Unhandled case in encodeTime()")
+  }
+
+  var s string
+  var err error
+  if tag == UTCTimeTag {
+    s, err = UTCTime(t)
+  } else {
+    s, err = GeneralizedTime(t)
+  }
+  return []byte(s), err
+}
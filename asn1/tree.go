@@ -23,6 +23,8 @@ package asn1
 import "os"
 import "fmt"
 import "regexp"
+import "strings"
+import "strconv"
 
 // set this to true to get debug output to stderr
 var Debug = false
@@ -67,16 +69,20 @@ const (
   undefinedNode = iota
   
   // The root node of the parsed ASN.1 Tree. The children of this node are
-  // typeDefNode and valueDefNode nodes.
+  // moduleNode nodes.
   rootNode
   
+  // An ASN.1 module, i.e. "DEFINITIONS ... BEGIN ... END" optionally preceded by
+  // a module identifier. The children of this node are typeDefNode and valueDefNode nodes.
+  moduleNode
+  
   // A type definition (upper case identifier). These nodes only occur as immediate
-  // children of rootNode. In particular structures within structures do not
+  // children of moduleNode. In particular structures within structures do not
   // have this type. They have type fieldNode.
   typeDefNode
   
   // A value definition (lower case identifier). These nodes only occur as
-  // immediate children of rootNode. In particular named integers within
+  // immediate children of moduleNode. In particular named integers within
   // an INTEGER type are not nodes of this type. They aren't nodes at all but
   // instead are stored in the namedints map.
   valueDefNode
@@ -103,15 +109,16 @@ type Tree struct {
   // in the case of CHOICE.
   // During resolve phase, this is filled in with the basic type tags for all
   // nodes that do not have a tag declared in the source.
-  // Each tag is followed by a 0 byte as placeholder for the length (and
-  // to easily separate the tags without having to parse them). During
+  // Each tag is followed by a 0 byte as placeholder for the length. During
   // DER-encoding the length will be filled in (together with additional bytes
-  // if the 1 byte is not enough).
+  // if the 1 byte is not enough). The last byte of a multi-byte tag may be 0,
+  // too (e.g. [128]), so use splitTags() to separate the tags.
   tags []byte
   
   // The tag the node has in the ASN.1 source, or -1 if there is none.
-  // The tag includes the class bits 7 and 8. This means that the tag must be in the
-  // range 0..63.
+  // The tag includes the class bits 7 and 8. Bits 1-6 contain the lower 6 bits of
+  // the tag number and the remaining bits of the tag number start at bit 9.
+  // Use tagNumber() to extract the tag number.
   // This field is only used to be able to produce string output that matches
   // the input from which the node was parsed. For DER-encoding, the tags field
   // above is used.
@@ -127,6 +134,7 @@ type Tree struct {
   // the default value to use when the field is omitted.
   optional bool
   
+  // moduleNode: the module reference (e.g. "PKIX1Explicit88") or "" if there is none
   // typeDefNode: the (upper-case) name of the type being defined
   // valueDefNode: the (lower-case) name of the value being defined
   // fieldNode: the (lower-case) name of the field within the sequence
@@ -193,6 +201,14 @@ type Tree struct {
   // "$'1.2.3.4' OBJECT IDENTIFIER".
   isAny bool
   
+  // This is only set for an instanceNode that is the result of instantiating an ANY from
+  // an Unmarshalled whose tag is not one of the UNIVERSAL tags handled by instantiateANY(),
+  // e.g. the context-specific tag of an unknown extension addition. The original tag is kept
+  // as the last tag of the instance, so that it is encoded exactly as it was decoded.
+  // The basictype is OCTET_STRING for a primitive encoding and SEQUENCE_OF (with ANY
+  // elements) for a constructed encoding. JSON output contains the complete DER encoding.
+  isRaw bool
+  
   // If the basictype is one of the compound types (SEQUENCE, SEQUENCE_OF, CHOICE, SET, SET_OF)
   // this contains the list of nodes within the compound. The type of the child nodes is
   // instanceNode, ofNode or fieldNode.
@@ -206,6 +222,36 @@ type Tree struct {
   // NOTE: This does NOT included named components of OBJECT_IDENTIFIERs.
   namedints map[string]int
   
+  // If basictype is OCTET_STRING, this is the UNIVERSAL tag number of the string or time
+  // type, e.g. 4 for OCTET STRING, 22 for IA5String and 24 for GeneralizedTime. Unlike
+  // the last tag in tags, this is not affected by IMPLICIT tags from the ASN.1 source.
+  // Like namedints this is filled in during post processing for nodes that are defined
+  // as a non-basic type. 0 for other basictypes and for instances with isRaw == true.
+  stringTag int
+  
+  // If basictype is SEQUENCE, SET, CHOICE or ENUMERATED this is the number of extension
+  // markers "..." in the definition (0, 1 or 2). The type is extensible iff this is not 0.
+  // Like namedints this is filled in during post processing for nodes that are defined
+  // as a non-basic type.
+  extensionMarkers int
+  
+  // fieldNode: true if the field is an extension addition, i.e. it follows the first
+  // extension marker (directly or within an extension addition group "[[...]]") and
+  // precedes the second one.
+  extension bool
+  
+  // The SIZE constraint (e.g. "(SIZE (1..ub-name))") and the value range constraint
+  // (e.g. "(0..MAX)") that apply to this node, or nil if there is none.
+  // During parsing only the source of the constraint is stored. Post-processing
+  // resolves it and combines it with the constraints of the type referenced by typename
+  // (see constraint.go).
+  size *constraint
+  valueRange *constraint
+  
+  // For all nodes created by the parser (except rootNode) this is the header of the
+  // module in which the node is defined. For other nodes it is nil.
+  module *moduleHeader
+  
   // The complete ASN.1 source whose parsing created this node.
   src string
   
@@ -213,6 +259,44 @@ type Tree struct {
   pos int
 }
 
+// Information from the header of an ASN.1 module, i.e. the parts before the
+// first type or value definition.
+type moduleHeader struct {
+  // The module reference, e.g. "PKIX1Explicit88", or "" if the module has none.
+  name string
+  
+  // The module's OBJECT IDENTIFIER as it appears in the ASN.1 source
+  // (e.g. "{ iso(1) identified-organization(3) ... }"), or "" if there is none.
+  oid string
+  
+  // True if the module is declared with "AUTOMATIC TAGS". In that case the moduleNode's
+  // implicit field is also true, because AUTOMATIC TAGS implies IMPLICIT for tags
+  // that appear in the source.
+  automatic bool
+  
+  // The symbols listed after EXPORTS. nil if the module has no EXPORTS clause
+  // or has "EXPORTS ALL", i.e. if all of its definitions are exported.
+  exports map[string]bool
+  
+  // Maps the symbols listed after IMPORTS to the modules they are imported from.
+  imports map[string]*importedSymbol
+  
+  // The ASN.1 source the module has been parsed from.
+  src string
+  
+  // The character index in src of the beginning of the module.
+  pos int
+}
+
+// A symbol listed after IMPORTS.
+type importedSymbol struct {
+  // The name of the module after "FROM".
+  module string
+  
+  // The character index in the module's src of the symbol.
+  pos int
+}
+
 // Contains ASN.1 DEFINITIONS of types and values.
 type Definitions struct {
   // The rootNode whose children are the typeDefNodes and valueDefNodes for the
@@ -220,10 +304,14 @@ type Definitions struct {
   tree *Tree
   // For quick access this maps the name of a type to its node. The map may have
   // entries that are not part of this.tree but are imported from elsewhere.
+  // Types defined in a module with a name are also accessible via their qualified
+  // name (e.g. "PKIX1Explicit88.Certificate"). If modules define types of the same
+  // name, the unqualified name refers to the definition that was parsed first.
   typedefs  map[string]*Tree
-  // For quick access this maps the name of a value to its node. The map may have
-  // entries that are not part of this.tree but are imported from elsewhere.
+  // Like typedefs, but for values.
   valuedefs map[string]*Tree
+  // Maps the names of all modules that have a name to their headers.
+  modules map[string]*moduleHeader
 }
 
 // Returns the names of all values that are defined.
@@ -330,6 +418,16 @@ func (defs *Definitions) HasType(name string) bool {
   return have_type
 }
 
+// Returns the name of the module in which the type called name is defined.
+// Returns "" if the type is not defined, is a standard type or is defined in a
+// module without a name.
+func (defs *Definitions) TypeModule(name string) string {
+  if t := defs.typedefs[name]; t != nil && t.module != nil {
+    return t.module.name
+  }
+  return ""
+}
+
 // Returns true iff value name is defined.
 func (defs *Definitions) HasValue(name string) bool {
   _, have_val := defs.valuedefs[name]
@@ -351,3 +449,31 @@ type Instance Tree
 func (i *Instance) Type() string {
   return typeName((*Tree)(i))
 }
+
+// Returns the component of this instance identified by path, which is a list
+// of field names separated by ".", e.g. "tbsCertificate.validity.notAfter".
+// The elements of a SEQUENCE OF or SET OF are identified by their index, starting
+// at 0. A CHOICE only has the component whose name is the chosen alternative.
+func (i *Instance) Field(path string) (*Instance, error) {
+  t := (*Tree)(i)
+  for _, name := range strings.Split(path, ".") {
+    var next *Tree
+    if t.basictype == SEQUENCE_OF || t.basictype == SET_OF {
+      if idx, err := strconv.Atoi(name); err == nil && idx >= 0 && idx < len(t.children) {
+        next = t.children[idx]
+      }
+    } else {
+      for _, c := range t.children {
+        if c.name == name {
+          next = c
+          break
+        }
+      }
+    }
+    if next == nil {
+      return nil, fmt.Errorf("%v has no component \"%v\"", typeName(t), name)
+    }
+    t = next
+  }
+  return (*Instance)(t), nil
+}
@@ -24,18 +24,26 @@ package main
 import (
          "os"
          "fmt"
+         "bytes"
          "crypto"
          "crypto/x509"
          "crypto/rand"
          "crypto/rsa"
          "crypto/ecdsa"
+         "crypto/ed25519"
          "crypto/elliptic"
+         "crypto/sha256"
          "hash"
          "strings"
          "io/ioutil"
          "encoding/json"
          "encoding/pem"
+         "encoding/base64"
+         stdasn1 "encoding/asn1"
+         "crypto/x509/pkix"
+         "math"
          "math/big"
+         "time"
          
          "winterdrache.de/golib/util"
          
@@ -56,6 +64,9 @@ func encodeDER(stack_ *[]*asn1.CookStackElement, location string) error {
                             if err != nil { panic(err) } // should never happen
                             *stack_ = append(stack[0:len(stack)-1], &asn1.CookStackElement{Value: derbytes})
     case *rsa.PrivateKey: *stack_ = append(stack[0:len(stack)-1], &asn1.CookStackElement{Value: x509.MarshalPKCS1PrivateKey(data)})
+    case ed25519.PrivateKey: derbytes, err := x509.MarshalPKCS8PrivateKey(data)
+                             if err != nil { panic(err) } // should never happen
+                             *stack_ = append(stack[0:len(stack)-1], &asn1.CookStackElement{Value: derbytes})
     default: return fmt.Errorf("%vencode(DER) called with argument of unsupported type \"%T\"", location, data)
   }
   
@@ -77,6 +88,14 @@ func encodePEM(stack_ *[]*asn1.CookStackElement, location string) error {
         derbytes = data.DER()
         switch data.Type() {
           case "Certificate": pemType = "CERTIFICATE"
+          case "CertificationRequest": pemType = "CERTIFICATE REQUEST"
+          case "CertificateList": pemType = "X509 CRL"
+          case "PrivateKeyInfo": pemType = "PRIVATE KEY"
+          case "EncryptedPrivateKeyInfo": pemType = "ENCRYPTED PRIVATE KEY"
+          case "SubjectPublicKeyInfo": pemType = "PUBLIC KEY"
+          case "RSAPrivateKey": pemType = "RSA PRIVATE KEY"
+          case "RSAPublicKey": pemType = "RSA PUBLIC KEY"
+          case "ECPrivateKey": pemType = "EC PRIVATE KEY"
         }
     
     case *ecdsa.PrivateKey: 
@@ -87,6 +106,12 @@ func encodePEM(stack_ *[]*asn1.CookStackElement, location string) error {
     case *rsa.PrivateKey: 
         derbytes = x509.MarshalPKCS1PrivateKey(data)
         pemType = "RSA PRIVATE KEY"
+    
+    // Ed25519 keys have no traditional format, so they are always written as PKCS#8
+    case ed25519.PrivateKey:
+        derbytes, err = x509.MarshalPKCS8PrivateKey(data)
+        if err != nil { panic(err) } // should never happen
+        pemType = "PRIVATE KEY"
         
     default: return fmt.Errorf("%vencode(PEM) called with argument of unsupported type \"%T\"", location, data)
   }
@@ -96,6 +121,247 @@ func encodePEM(stack_ *[]*asn1.CookStackElement, location string) error {
   return nil
 }
 
+// Returns the top element of the stack which must be a private key,
+// for use by function name.
+func privateKey(stack []*asn1.CookStackElement, name string, location string) (crypto.Signer, error) {
+  if len(stack) == 0 {
+    return nil, fmt.Errorf("%v%v called on empty stack", location, name)
+  }
+  signer, ok := stack[len(stack)-1].Value.(crypto.Signer)
+  if !ok {
+    return nil, fmt.Errorf("%v%v called, but top element of stack is not a key. Use the key() function!", location, name)
+  }
+  return signer, nil
+}
+
+// Returns the public key for the top element of the stack which must be
+// a private key or a SubjectPublicKeyInfo, for use by function name.
+func publicKey(stack []*asn1.CookStackElement, name string, location string) (crypto.PublicKey, error) {
+  if len(stack) == 0 {
+    return nil, fmt.Errorf("%v%v called on empty stack", location, name)
+  }
+  pub, ok, err := toPublicKey(stack[len(stack)-1].Value)
+  if err != nil {
+    return nil, fmt.Errorf("%v%v error: %v", location, name, err)
+  }
+  if !ok {
+    return nil, fmt.Errorf("%v%v called, but top element of stack is neither a key nor a SubjectPublicKeyInfo", location, name)
+  }
+  return pub, nil
+}
+
+// If data is a private key or a SubjectPublicKeyInfo, returns the public key and true.
+// Otherwise returns false.
+func toPublicKey(data interface{}) (crypto.PublicKey, bool, error) {
+  switch data := data.(type) {
+    case crypto.Signer: return data.Public(), true, nil
+    case *asn1.Instance:
+        if data.Type() == "SubjectPublicKeyInfo" {
+          pub, err := x509.ParsePKIXPublicKey(data.DER())
+          return pub, true, err
+        }
+  }
+  return nil, false, nil
+}
+
+// If data is a private key, a SubjectPublicKeyInfo or a certificate (as *asn1.Instance
+// or DER or PEM encoded byte-array), returns the DER encoding of the SubjectPublicKeyInfo
+// and true. Otherwise returns false.
+func toSubjectPublicKeyInfo(data interface{}) ([]byte, bool, error) {
+  var certder []byte
+  switch data := data.(type) {
+    case crypto.Signer:
+        spki, err := x509.MarshalPKIXPublicKey(data.Public())
+        return spki, true, err
+    case *asn1.Instance:
+        switch data.Type() {
+          case "SubjectPublicKeyInfo": return data.DER(), true, nil
+          case "Certificate": certder = data.DER()
+          default: return nil, false, nil
+        }
+    case []byte:
+        certder = data
+        if block, _ := pem.Decode(data); block != nil && block.Type == "CERTIFICATE" {
+          certder = block.Bytes
+        }
+    default: return nil, false, nil
+  }
+
+  cert, err := x509.ParseCertificate(certder)
+  if err != nil {
+    return nil, true, err
+  }
+  return cert.RawSubjectPublicKeyInfo, true, nil
+}
+
+func keyIdentifierSHA1(stack_ *[]*asn1.CookStackElement, location string) error {
+  return keyIdentifier(stack_, location, "keyIdentifier()", crypto.SHA1)
+}
+
+func keyIdentifierSHA256(stack_ *[]*asn1.CookStackElement, location string) error {
+  return keyIdentifier(stack_, location, "keyIdentifier(SHA-256)", crypto.SHA256)
+}
+
+// Replaces the key or certificate (see toSubjectPublicKeyInfo()) on top of the stack
+// with a byte-array containing the leftmost 160 bits of the hash h over the
+// subjectPublicKey BIT STRING (excluding tag, length and number of unused bits).
+// For crypto.SHA1 this is method 1 from RFC 5280 section 4.2.1.2, for crypto.SHA256 it
+// is method 1 from RFC 7093.
+func keyIdentifier(stack_ *[]*asn1.CookStackElement, location string, name string, h crypto.Hash) error {
+  stack := *stack_
+  if len(stack) == 0 {
+    return fmt.Errorf("%v%v called on empty stack", location, name)
+  }
+  spki, ok, err := toSubjectPublicKeyInfo(stack[len(stack)-1].Value)
+  if err != nil {
+    return fmt.Errorf("%v%v error: %v", location, name, err)
+  }
+  if !ok {
+    return fmt.Errorf("%v%v called, but top element of stack is neither a key nor a SubjectPublicKeyInfo nor a certificate", location, name)
+  }
+
+  var info struct {
+    Algorithm pkix.AlgorithmIdentifier
+    PublicKey stdasn1.BitString
+  }
+  if _, err := stdasn1.Unmarshal(spki, &info); err != nil {
+    return fmt.Errorf("%v%v error: %v", location, name, err)
+  }
+
+  hash := h.New()
+  hash.Write(info.PublicKey.Bytes)
+  *stack_ = append(stack[0:len(stack)-1], &asn1.CookStackElement{Value: hash.Sum(nil)[0:20]})
+  return nil
+}
+
+func encodePKCS8(stack_ *[]*asn1.CookStackElement, location string) error {
+  stack := *stack_
+  signer, err := privateKey(stack, "encode(PKCS8)", location)
+  if err != nil {
+    return err
+  }
+  derbytes, err := x509.MarshalPKCS8PrivateKey(signer)
+  if err != nil {
+    return fmt.Errorf("%vencode(PKCS8) error: %v", location, err)
+  }
+  *stack_ = append(stack[0:len(stack)-1], &asn1.CookStackElement{Value: derbytes})
+  return nil
+}
+
+func encodePKCS8PEM(stack_ *[]*asn1.CookStackElement, location string) error {
+  stack := *stack_
+  signer, err := privateKey(stack, "encode(PKCS8-PEM)", location)
+  if err != nil {
+    return err
+  }
+  derbytes, err := x509.MarshalPKCS8PrivateKey(signer)
+  if err != nil {
+    return fmt.Errorf("%vencode(PKCS8-PEM) error: %v", location, err)
+  }
+  pemBlock := &pem.Block{Type: "PRIVATE KEY", Bytes: derbytes}
+  *stack_ = append(stack[0:len(stack)-1], &asn1.CookStackElement{Value: pem.EncodeToMemory(pemBlock)})
+  return nil
+}
+
+func encodeSPKIPEM(stack_ *[]*asn1.CookStackElement, location string) error {
+  stack := *stack_
+  pub, err := publicKey(stack, "encode(SPKI-PEM)", location)
+  if err != nil {
+    return err
+  }
+  derbytes, err := x509.MarshalPKIXPublicKey(pub)
+  if err != nil {
+    return fmt.Errorf("%vencode(SPKI-PEM) error: %v", location, err)
+  }
+  pemBlock := &pem.Block{Type: "PUBLIC KEY", Bytes: derbytes}
+  *stack_ = append(stack[0:len(stack)-1], &asn1.CookStackElement{Value: pem.EncodeToMemory(pemBlock)})
+  return nil
+}
+
+// Produces a private key file in OpenSSH's own format ("OPENSSH PRIVATE KEY").
+func encodeOpenSSHPrivate(stack_ *[]*asn1.CookStackElement, location string) error {
+  stack := *stack_
+  key, err := privateKey(stack, "encode(OpenSSH-private)", location)
+  if err != nil {
+    return err
+  }
+  data, err := asn1.MarshalOpenSSHPrivateKey(key, "")
+  if err != nil {
+    return fmt.Errorf("%vencode(OpenSSH-private) error: %v", location, err)
+  }
+  pemBlock := &pem.Block{Type: "OPENSSH PRIVATE KEY", Bytes: data}
+  *stack_ = append(stack[0:len(stack)-1], &asn1.CookStackElement{Value: pem.EncodeToMemory(pemBlock)})
+  return nil
+}
+
+// Produces a public key line as found in OpenSSH's authorized_keys files.
+// If the top of the stack is an OpenSSH certificate (see sign(OpenSSH)), produces
+// the contents of a *-cert.pub file.
+func encodeOpenSSH(stack_ *[]*asn1.CookStackElement, location string) error {
+  stack := *stack_
+  if len(stack) > 0 {
+    if cert, ok := stack[len(stack)-1].Value.(*asn1.SSHCertificate); ok {
+      certtype, err := asn1.SSHCertType(cert.Key)
+      if err != nil {
+        return fmt.Errorf("%vencode(OpenSSH) error: %v", location, err)
+      }
+      blob, err := cert.Marshal()
+      if err != nil {
+        return fmt.Errorf("%vencode(OpenSSH) error: %v", location, err)
+      }
+      line := certtype + " " + base64.StdEncoding.EncodeToString(blob) + "\n"
+      *stack_ = append(stack[0:len(stack)-1], &asn1.CookStackElement{Value: []byte(line)})
+      return nil
+    }
+  }
+
+  pub, err := publicKey(stack, "encode(OpenSSH)", location)
+  if err != nil {
+    return err
+  }
+  keytype, err := asn1.SSHKeyType(pub)
+  if err != nil {
+    return fmt.Errorf("%vencode(OpenSSH) error: %v", location, err)
+  }
+  blob, err := asn1.MarshalSSHPublicKey(pub)
+  if err != nil {
+    return fmt.Errorf("%vencode(OpenSSH) error: %v", location, err)
+  }
+  line := keytype + " " + base64.StdEncoding.EncodeToString(blob) + "\n"
+  *stack_ = append(stack[0:len(stack)-1], &asn1.CookStackElement{Value: []byte(line)})
+  return nil
+}
+
+func encodeBase64(stack_ *[]*asn1.CookStackElement, location string) error {
+  stack := *stack_
+  if len(stack) == 0 {
+    return fmt.Errorf("%vencode(base64) called on empty stack", location)
+  }
+
+  var data []byte
+  switch d := stack[len(stack)-1].Value.(type) {
+    case *asn1.Instance: data = d.DER()
+    case []byte: data = d
+    default: return fmt.Errorf("%vencode(base64) called with argument of unsupported type \"%T\"", location, d)
+  }
+
+  // break lines after 76 characters like base64(1) and MIME (RFC 2045) do, so that
+  // bare base64 input to certificate-disassembler is reproduced unchanged.
+  // PEM uses 64 characters, but that is what encode(PEM) is for.
+  b64 := base64.StdEncoding.EncodeToString(data)
+  encoded := []byte{}
+  for len(b64) > 76 {
+    encoded = append(encoded, b64[0:76]...)
+    encoded = append(encoded, '\n')
+    b64 = b64[76:]
+  }
+  encoded = append(encoded, b64...)
+  encoded = append(encoded, '\n')
+
+  *stack_ = append(stack[0:len(stack)-1], &asn1.CookStackElement{Value: encoded})
+  return nil
+}
+
 func decodeHex(stack_ *[]*asn1.CookStackElement, location string) error {
   stack := *stack_
   if len(stack) == 0 {
@@ -141,15 +407,50 @@ func decodeHex(stack_ *[]*asn1.CookStackElement, location string) error {
   return nil
 }
 
+// Replaces the DER encoding of a single ASN.1 element on top of the stack with the
+// asn1.Unmarshalled decoded from it. This is used by the disassembler to represent
+// elements of unknown type (e.g. unknown extension additions).
+func decodeDER(stack_ *[]*asn1.CookStackElement, location string) error {
+  stack := *stack_
+  if len(stack) == 0 {
+    return fmt.Errorf("%vdecode(DER) called on empty stack", location)
+  }
+  data, ok := stack[len(stack)-1].Value.([]byte)
+  if !ok {
+    return fmt.Errorf("%vdecode(DER) requires top element of stack to be a byte array", location)
+  }
+  
+  unmarshalled, err := asn1.UnmarshalDERWithError(data, 0)
+  if err != nil {
+    return fmt.Errorf("%vdecode(DER): %v", location, err)
+  }
+  
+  element, count := unmarshalled.First()
+  if count > 1 {
+    return fmt.Errorf("%vdecode(DER): argument contains more than 1 element", location)
+  }
+  if element == nil {
+    return fmt.Errorf("%vdecode(DER): argument is empty", location)
+  }
+  
+  *stack_ = append(stack[0:len(stack)-1], &asn1.CookStackElement{Value: element})
+  return nil
+}
+
 func write_if_missing(stack_ *[]*asn1.CookStackElement, location string) error {
-  return writeimpl(stack_, location, false)
+  return writeimpl(stack_, location, os.O_EXCL)
 }
 
 func write(stack_ *[]*asn1.CookStackElement, location string) error {
-  return writeimpl(stack_, location, true)
+  return writeimpl(stack_, location, os.O_TRUNC)
+}
+
+func write_append(stack_ *[]*asn1.CookStackElement, location string) error {
+  return writeimpl(stack_, location, os.O_APPEND)
 }
 
-func writeimpl(stack_ *[]*asn1.CookStackElement, location string, overwrite bool) error {
+// mode is os.O_TRUNC (overwrite), os.O_EXCL (only if missing) or os.O_APPEND.
+func writeimpl(stack_ *[]*asn1.CookStackElement, location string, mode int) error {
   stack := *stack_
   if len(stack) < 2 {
     return fmt.Errorf("%vwrite() called on stack with fewer than 2 elements", location)
@@ -165,15 +466,13 @@ func writeimpl(stack_ *[]*asn1.CookStackElement, location string, overwrite bool
   if ok2 { data1, file1 = data2, file2 }
   if ok3 && ok4 { data1, file1 = []byte(file1), file2 }
   
-  flag := os.O_WRONLY | os.O_CREATE
-  if overwrite { flag = flag | os.O_TRUNC } else { flag = flag | os.O_EXCL }
-  f, err := os.OpenFile(file1, flag, 0644)
+  f, err := os.OpenFile(file1, os.O_WRONLY | os.O_CREATE | mode, 0644)
   if err == nil {
     defer f.Close()
     _, err = util.WriteAll(f, data1)
   }
   if err != nil {
-    if overwrite || !os.IsExist(err) {
+    if mode != os.O_EXCL || !os.IsExist(err) {
       return fmt.Errorf("%vwrite() error: %v", location, err)
     }
   }
@@ -189,23 +488,142 @@ func key(stack_ *[]*asn1.CookStackElement, location string) error {
     return fmt.Errorf("%vkey() called on empty stack", location)
   }
 
-  fname, ok := stack[len(stack)-1].Value.(string)
+  // optional passphrase on top of the file name
+  var pass []byte
+  args := 1
+  if spec, ok := stack[len(stack)-1].Value.(string); ok && isPassphrase(spec) && len(stack) > 1 {
+    var err error
+    pass, err = passphrase(spec, "key()", location)
+    if err != nil {
+      return err
+    }
+    args = 2
+  }
+
+  fname, ok := stack[len(stack)-args].Value.(string)
   if !ok {
     return fmt.Errorf("%vkey() called, but top element of stack is not a file name", location)
   }
 
   var signer crypto.Signer
-  file, err := os.Open(fname)
+  data, err := ioutil.ReadFile(fname)
   if err == nil {
-    defer file.Close()
-    signer, err = asn1.ReadNextKey(file)
+    if block, _ := pem.Decode(data); block != nil && block.Type == "OPENSSH PRIVATE KEY" {
+      signer, err = asn1.ParseOpenSSHPrivateKey(block.Bytes)
+    } else {
+      signer, err = asn1.ReadNextEncryptedKey(bytes.NewReader(data), pass)
+    }
   }
 
   if err != nil {
     return fmt.Errorf("%vkey() error: %v", location, err)
   }
   
-  *stack_ = append(stack[0:len(stack)-1], &asn1.CookStackElement{Value: signer})
+  *stack_ = append(stack[0:len(stack)-args], &asn1.CookStackElement{Value: signer})
+  return nil
+}
+
+// Returns true iff spec has one of the prefixes understood by passphrase().
+func isPassphrase(spec string) bool {
+  return strings.HasPrefix(spec, "pass:") || strings.HasPrefix(spec, "file:") || strings.HasPrefix(spec, "env:")
+}
+
+// Returns the passphrase described by spec, for use by function name. spec is one of
+//   pass:<passphrase>
+//   file:<file name>  (the 1st line of the file is the passphrase)
+//   env:<variable>    (the value of the environment variable is the passphrase)
+func passphrase(spec string, name string, location string) ([]byte, error) {
+  switch {
+    case strings.HasPrefix(spec, "pass:"): return []byte(spec[5:]), nil
+    case strings.HasPrefix(spec, "file:"):
+        data, err := ioutil.ReadFile(spec[5:])
+        if err != nil {
+          return nil, fmt.Errorf("%v%v error: %v", location, name, err)
+        }
+        return []byte(strings.TrimRight(strings.SplitN(string(data), "\n", 2)[0], "\r")), nil
+    case strings.HasPrefix(spec, "env:"):
+        value, ok := os.LookupEnv(spec[4:])
+        if !ok {
+          return nil, fmt.Errorf("%v%v error: Environment variable %v is not set", location, name, spec[4:])
+        }
+        return []byte(value), nil
+  }
+  return nil, fmt.Errorf("%v%v error: Passphrase must start with \"pass:\", \"file:\" or \"env:\"", location, name)
+}
+
+// Takes a key and a passphrase and produces an unmarshalled EncryptedPrivateKeyInfo
+// that can be instantiated with the type of that name.
+func encryptPKCS8(stack_ *[]*asn1.CookStackElement, location string) error {
+  stack := *stack_
+  if len(stack) < 2 {
+    return fmt.Errorf("%vencrypt(PKCS8) called on stack with fewer than 2 elements", location)
+  }
+  spec1, ok1 := stack[len(stack)-1].Value.(string)
+  spec2, ok2 := stack[len(stack)-2].Value.(string)
+  key1, ok3 := stack[len(stack)-1].Value.(crypto.Signer)
+  key2, ok4 := stack[len(stack)-2].Value.(crypto.Signer)
+  if !((ok1 && ok4) || (ok2 && ok3)) {
+    return fmt.Errorf("%vencrypt(PKCS8) requires the top 2 elements of the stack to be a key and a passphrase", location)
+  }
+  if ok2 { spec1, key2 = spec2, key1 }
+  
+  pass, err := passphrase(spec1, "encrypt(PKCS8)", location)
+  if err != nil {
+    return err
+  }
+  
+  derbytes, err := x509.MarshalPKCS8PrivateKey(key2)
+  if err == nil {
+    derbytes, err = asn1.EncryptPKCS8(derbytes, pass)
+  }
+  if err != nil {
+    return fmt.Errorf("%vencrypt(PKCS8) error: %v", location, err)
+  }
+  
+  unmarshaled := asn1.UnmarshalDER(derbytes, 0)
+  unmarshaled = unmarshaled.Data[asn1.Rawtag([]byte{0x30})].(*asn1.UnmarshalledConstructed)
+  
+  *stack_ = append(stack[0:len(stack)-2], &asn1.CookStackElement{Value: unmarshaled})
+  return nil
+}
+
+// Replaces the file name on top of the stack with an *asn1.Instance of type
+// Certificate read from that file, which may be PEM or DER encoded.
+func cert(stack_ *[]*asn1.CookStackElement, location string) error {
+  stack := *stack_
+  if len(stack) == 0 {
+    return fmt.Errorf("%vcert() called on empty stack", location)
+  }
+  fname, ok := stack[len(stack)-1].Value.(string)
+  if !ok {
+    return fmt.Errorf("%vcert() called, but top element of stack is not a file name", location)
+  }
+
+  data, err := ioutil.ReadFile(fname)
+  if err != nil {
+    return fmt.Errorf("%vcert() error: %v", location, err)
+  }
+  if block, _ := pem.Decode(data); block != nil {
+    if block.Type != "CERTIFICATE" {
+      return fmt.Errorf("%vcert() error: %v contains \"%v\" instead of \"CERTIFICATE\"", location, fname, block.Type)
+    }
+    data = block.Bytes
+  }
+
+  unmarshaled, err := asn1.UnmarshalDERWithError(data, 0)
+  if err != nil {
+    return fmt.Errorf("%vcert() error: %v: %v", location, fname, err)
+  }
+  top, count := unmarshaled.First()
+  if count != 1 {
+    return fmt.Errorf("%vcert() error: %v contains %v DER elements instead of 1", location, fname, count)
+  }
+  inst, err := defs.Instantiate("Certificate", top)
+  if err != nil {
+    return fmt.Errorf("%vcert() error: %v: %v", location, fname, err)
+  }
+
+  *stack_ = append(stack[0:len(stack)-1], &asn1.CookStackElement{Value: inst})
   return nil
 }
 
@@ -256,11 +674,19 @@ func keygen(stack_ *[]*asn1.CookStackElement, location string) error {
                       
                       // secp521r1
                       case "1.3.132.0.35": curve = elliptic.P521()
+                      
+                      // id-Ed25519
+                      case "1.3.101.112": _, signer, err = ed25519.GenerateKey(rand.Reader)
+                      
+                      // id-Ed448
+                      case "1.3.101.113": return fmt.Errorf("%vkeygen() error: Ed448 keys are not supported", location)
                           
                       default: return fmt.Errorf("%vkeygen() called with unsupported OBJECT IDENTIFIER \"%v\"", location, curveoid)
                     }
                     
-                    signer, err = ecdsa.GenerateKey(curve, rand.Reader)
+                    if curve != nil {
+                      signer, err = ecdsa.GenerateKey(curve, rand.Reader)
+                    }
                     if err != nil {
                       return fmt.Errorf("%vkeygen() error: %v", location, err)
                     }
@@ -296,6 +722,105 @@ func subjectPublicKeyInfo(stack_ *[]*asn1.CookStackElement, location string) err
   return nil
 }
 
+// Maps the OBJECT IDENTIFIERs of hash algorithms that may occur in
+// RSASSA-PSS-params to the respective crypto.Hash.
+var hashAlgorithms = map[string]crypto.Hash{
+  "1.3.14.3.2.26": crypto.SHA1,            // id-sha1
+  "2.16.840.1.101.3.4.2.4": crypto.SHA224, // id-sha224
+  "2.16.840.1.101.3.4.2.1": crypto.SHA256, // id-sha256
+  "2.16.840.1.101.3.4.2.2": crypto.SHA384, // id-sha384
+  "2.16.840.1.101.3.4.2.3": crypto.SHA512, // id-sha512
+}
+
+// RSASSA-PSS-params from RFC 4055 for decoding with encoding/asn1.
+type pssParams struct {
+  HashAlgorithm    pkix.AlgorithmIdentifier `asn1:"optional,explicit,tag:0"`
+  MaskGenAlgorithm pkix.AlgorithmIdentifier `asn1:"optional,explicit,tag:1"`
+  SaltLength       int                      `asn1:"optional,explicit,tag:2,default:20"`
+  TrailerField     int                      `asn1:"optional,explicit,tag:3,default:1"`
+}
+
+// Takes the parameters of an id-RSASSA-PSS AlgorithmIdentifier and converts them
+// to *rsa.PSSOptions. parameters may be nil (or JSON null), in which case the defaults
+// from RFC 4055 (SHA-1, MGF1 with SHA-1, salt length 20) are used. Otherwise it
+// must be an instance of RSASSA-PSS-params.
+func pssOptions(parameters interface{}, location string) (*rsa.PSSOptions, error) {
+  var params pssParams
+  params.SaltLength = 20
+  params.TrailerField = 1
+  
+  switch parm := parameters.(type) {
+    case nil: {} // use defaults
+    case *asn1.Instance: 
+        if parm.Type() != "NULL" {
+          rest, err := stdasn1.Unmarshal(parm.DER(), &params)
+          if err == nil && len(rest) != 0 {
+            err = fmt.Errorf("trailing garbage")
+          }
+          if err != nil {
+            return nil, fmt.Errorf("%vsign() error: Could not decode RSASSA-PSS-params: %v", location, err)
+          }
+        }
+    default: return nil, fmt.Errorf("%vsign() error: \"parameters\" of id-RSASSA-PSS must be RSASSA-PSS-params", location)
+  }
+  
+  hashoid := "1.3.14.3.2.26" // id-sha1
+  if len(params.HashAlgorithm.Algorithm) > 0 {
+    hashoid = params.HashAlgorithm.Algorithm.String()
+  }
+  cryptohash, ok := hashAlgorithms[hashoid]
+  if !ok {
+    return nil, fmt.Errorf("%vsign() error: Unsupported RSASSA-PSS hashAlgorithm \"%v\"", location, hashoid)
+  }
+  
+  if len(params.MaskGenAlgorithm.Algorithm) > 0 {
+    if mgfoid := params.MaskGenAlgorithm.Algorithm.String(); mgfoid != "1.2.840.113549.1.1.8" { // id-mgf1
+      return nil, fmt.Errorf("%vsign() error: Unsupported RSASSA-PSS maskGenAlgorithm \"%v\"", location, mgfoid)
+    }
+    var mgfhash pkix.AlgorithmIdentifier
+    _, err := stdasn1.Unmarshal(params.MaskGenAlgorithm.Parameters.FullBytes, &mgfhash)
+    if err != nil {
+      return nil, fmt.Errorf("%vsign() error: Could not decode hash algorithm of maskGenAlgorithm: %v", location, err)
+    }
+    if mgfhash.Algorithm.String() != hashoid {
+      // Go's crypto/rsa always uses the message hash for MGF1
+      return nil, fmt.Errorf("%vsign() error: MGF1 hash algorithm \"%v\" must be the same as hashAlgorithm \"%v\"", location, mgfhash.Algorithm, hashoid)
+    }
+  } else if hashoid != "1.3.14.3.2.26" {
+    return nil, fmt.Errorf("%vsign() error: Default maskGenAlgorithm (MGF1 with SHA-1) requires hashAlgorithm SHA-1", location)
+  }
+  
+  // A saltLength of 0 can not be expressed with rsa.PSSOptions because 0 is rsa.PSSSaltLengthAuto
+  if params.SaltLength <= 0 {
+    return nil, fmt.Errorf("%vsign() error: Unsupported RSASSA-PSS saltLength: %v", location, params.SaltLength)
+  }
+  
+  if params.TrailerField != 1 {
+    return nil, fmt.Errorf("%vsign() error: Unsupported RSASSA-PSS trailerField: %v", location, params.TrailerField)
+  }
+  
+  return &rsa.PSSOptions{SaltLength: params.SaltLength, Hash: cryptohash}, nil
+}
+
+// If value is an AlgorithmIdentifier structure or an *asn1.Instance of
+// AlgorithmIdentifier (e.g. from field(signatureAlgorithm)), returns it as a map
+// and true. Otherwise returns false.
+func algorithmIdentifier(value interface{}) (map[string]interface{}, bool) {
+  switch v := value.(type) {
+    case map[string]interface{}: return v, true
+    case *asn1.Instance:
+        if v.Type() != "AlgorithmIdentifier" { break }
+        algo := map[string]interface{}{}
+        for _, name := range []string{"algorithm", "parameters"} {
+          if field, err := v.Field(name); err == nil {
+            algo[name] = field
+          }
+        }
+        return algo, true
+  }
+  return nil, false
+}
+
 func sign(stack_ *[]*asn1.CookStackElement, location string) error {
   stack := *stack_
   if len(stack) < 3 {
@@ -307,9 +832,9 @@ func sign(stack_ *[]*asn1.CookStackElement, location string) error {
   key1, ok4 := stack[len(stack)-1].Value.(crypto.Signer)
   key2, ok5 := stack[len(stack)-2].Value.(crypto.Signer)
   key3, ok6 := stack[len(stack)-3].Value.(crypto.Signer)
-  algo1, ok7 := stack[len(stack)-1].Value.(map[string]interface{})
-  algo2, ok8 := stack[len(stack)-2].Value.(map[string]interface{})
-  algo3, ok9 := stack[len(stack)-3].Value.(map[string]interface{})
+  algo1, ok7 := algorithmIdentifier(stack[len(stack)-1].Value)
+  algo2, ok8 := algorithmIdentifier(stack[len(stack)-2].Value)
+  algo3, ok9 := algorithmIdentifier(stack[len(stack)-3].Value)
   if !((ok1||ok2||ok3) && (ok4||ok5||ok6) && (ok7||ok8||ok9)) {
     return fmt.Errorf("%vsign() requires the top 3 elements of the stack to be a byte-array, a key and an AlgorithmIdentifier structure", location)
   }
@@ -350,6 +875,7 @@ func sign(stack_ *[]*asn1.CookStackElement, location string) error {
   }
 
   var cryptohash crypto.Hash
+  var opts crypto.SignerOpts
   switch algooid {
     //md2WithRSAEncryption
     //case "1.2.840.113549.1.1.2": cryptohash = crypto.MD2
@@ -395,14 +921,44 @@ func sign(stack_ *[]*asn1.CookStackElement, location string) error {
 
     // sha512WithRSAEncryption
     case "1.2.840.113549.1.1.13": cryptohash = crypto.SHA512
+    
+    // id-RSASSA-PSS
+    case "1.2.840.113549.1.1.10": 
+        if _, isrsa := key.(*rsa.PrivateKey); !isrsa {
+          return fmt.Errorf("%vsign() error: id-RSASSA-PSS requires an RSA key", location)
+        }
+        pss, err := pssOptions(algo["parameters"], location)
+        if err != nil {
+          return err
+        }
+        cryptohash = pss.Hash
+        opts = pss
+    
+    // id-Ed25519 (PureEdDSA signs the message itself, not a hash of it)
+    case "1.3.101.112": 
+        if _, ised25519 := key.(ed25519.PrivateKey); !ised25519 {
+          return fmt.Errorf("%vsign() error: id-Ed25519 requires an Ed25519 key", location)
+        }
+        cryptohash = 0
+    
+    // id-Ed448
+    case "1.3.101.113": return fmt.Errorf("%vsign() error: Ed448 signatures are not supported", location)
 
     default: return fmt.Errorf("%vsign() error: Unknown signature algorithm OID \"%v\"", location, algooid)
   }
   
-  var hashhash hash.Hash
-  hashhash = cryptohash.New()
-  hashhash.Write(data)
-  sig, err := key.Sign(rand.Reader, hashhash.Sum(nil), cryptohash)
+  if opts == nil {
+    opts = cryptohash
+  }
+  
+  digest := data
+  if cryptohash != 0 {
+    var hashhash hash.Hash
+    hashhash = cryptohash.New()
+    hashhash.Write(data)
+    digest = hashhash.Sum(nil)
+  }
+  sig, err := key.Sign(rand.Reader, digest, opts)
   if err != nil {
     return fmt.Errorf("%vsign() error: %v", location, err)
   }
@@ -411,7 +967,350 @@ func sign(stack_ *[]*asn1.CookStackElement, location string) error {
   return nil
 }
 
-var funcs = map[string]asn1.CookStackFunc{"encode(DER)":encodeDER, "encode(PEM)":encodePEM, "decode(hex)":decodeHex, "write()": write, "write(if-missing)": write_if_missing, "key()": key, "subjectPublicKeyInfo()": subjectPublicKeyInfo, "sign()":sign, "keygen()": keygen}
+// Takes a structure describing an OpenSSH certificate and a CA key (in either order)
+// and returns the signed certificate. Use encode(OpenSSH) to produce a *-cert.pub file.
+// The structure has the following members (all but "key" are optional):
+//   "type":            "user" (default) or "host"
+//   "key":             the certified key (private key or SubjectPublicKeyInfo)
+//   "serial":          number
+//   "keyId":           string
+//   "principals":      array of user or host names (default: valid for all)
+//   "validAfter":      seconds since the epoch or RFC 3339 time (default: always)
+//   "validBefore":     seconds since the epoch or RFC 3339 time or "forever" (default)
+//   "criticalOptions": object mapping option names to values, e.g. "force-command"
+//   "extensions":      object mapping extension names to values (usually ""), e.g. "permit-pty"
+func signOpenSSH(stack_ *[]*asn1.CookStackElement, location string) error {
+  stack := *stack_
+  if len(stack) < 2 {
+    return fmt.Errorf("%vsign(OpenSSH) called on stack with fewer than 2 elements", location)
+  }
+  data1, ok1 := stack[len(stack)-1].Value.(map[string]interface{})
+  data2, ok2 := stack[len(stack)-2].Value.(map[string]interface{})
+  key1, ok3 := stack[len(stack)-1].Value.(crypto.Signer)
+  key2, ok4 := stack[len(stack)-2].Value.(crypto.Signer)
+  if !((ok1 && ok4) || (ok2 && ok3)) {
+    return fmt.Errorf("%vsign(OpenSSH) requires the top 2 elements of the stack to be a certificate structure and a key", location)
+  }
+  data, signkey := data1, key2
+  if ok2 { data, signkey = data2, key1 }
+
+  cert := &asn1.SSHCertificate{Type: asn1.SSHUserCert, ValidBefore: math.MaxUint64}
+  for name, value := range data {
+    var err error
+    switch name {
+      case "type":
+          switch value {
+            case "user": cert.Type = asn1.SSHUserCert
+            case "host": cert.Type = asn1.SSHHostCert
+            default: err = fmt.Errorf("must be \"user\" or \"host\"")
+          }
+      case "key":
+          var ok bool
+          cert.Key, ok, err = toPublicKey(value)
+          if err == nil && !ok {
+            err = fmt.Errorf("must be a key or a SubjectPublicKeyInfo")
+          }
+      case "serial": cert.Serial, err = sshNumber(value, false)
+      case "keyId":
+          var ok bool
+          if cert.KeyId, ok = value.(string); !ok {
+            err = fmt.Errorf("must be a string")
+          }
+      case "principals":
+          list, ok := value.([]interface{})
+          if !ok {
+            err = fmt.Errorf("must be an array of strings")
+          }
+          for _, p := range list {
+            if p, ok := p.(string); ok {
+              cert.ValidPrincipals = append(cert.ValidPrincipals, p)
+            } else {
+              err = fmt.Errorf("must be an array of strings")
+            }
+          }
+      case "validAfter": cert.ValidAfter, err = sshNumber(value, true)
+      case "validBefore":
+          if value == "forever" {
+            cert.ValidBefore = math.MaxUint64
+          } else {
+            cert.ValidBefore, err = sshNumber(value, true)
+          }
+      case "criticalOptions": cert.CriticalOptions, err = sshOptions(value)
+      case "extensions": cert.Extensions, err = sshOptions(value)
+      default: err = fmt.Errorf("unknown member")
+    }
+    if err != nil {
+      return fmt.Errorf("%vsign(OpenSSH) error: \"%v\" %v", location, name, err)
+    }
+  }
+  if cert.Key == nil {
+    return fmt.Errorf("%vsign(OpenSSH) error: certificate structure has no \"key\" member", location)
+  }
+
+  if err := cert.Sign(signkey); err != nil {
+    return fmt.Errorf("%vsign(OpenSSH) error: %v", location, err)
+  }
+  *stack_ = append(stack[0:len(stack)-2], &asn1.CookStackElement{Value: cert})
+  return nil
+}
+
+// Serial numbers generated by serial(random) and serial(hash) have this many bits.
+// 159 bits are the maximum that fits into the 20 octets permitted by RFC 5280
+// with the sign bit 0.
+const serialBits = 159
+
+// Pushes a random positive serial number as *big.Int.
+func serialRandom(stack_ *[]*asn1.CookStackElement, location string) error {
+  max := new(big.Int).Lsh(big.NewInt(1), serialBits)
+  for {
+    serial, err := rand.Int(rand.Reader, max)
+    if err != nil {
+      return fmt.Errorf("%vserial(random) error: %v", location, err)
+    }
+    if serial.Sign() > 0 {
+      *stack_ = append(*stack_, &asn1.CookStackElement{Value: serial})
+      return nil
+    }
+  }
+}
+
+// Replaces the top element of the stack (an *asn1.Instance, which is DER-encoded, or a
+// byte-array or string) with a positive serial number derived from its SHA-256 hash.
+func serialHash(stack_ *[]*asn1.CookStackElement, location string) error {
+  stack := *stack_
+  if len(stack) == 0 {
+    return fmt.Errorf("%vserial(hash) called on empty stack", location)
+  }
+
+  var data []byte
+  switch d := stack[len(stack)-1].Value.(type) {
+    case *asn1.Instance: data = d.DER()
+    case []byte: data = d
+    case string: data = []byte(d)
+    default: return fmt.Errorf("%vserial(hash) called with argument of unsupported type \"%T\"", location, d)
+  }
+
+  hash := sha256.Sum256(data)
+  serial := new(big.Int).SetBytes(hash[0:(serialBits+7)/8])
+  serial.Rsh(serial, uint((serialBits+7)/8*8 - serialBits))
+  if serial.Sign() == 0 {
+    serial.SetInt64(1)
+  }
+
+  *stack_ = append(stack[0:len(stack)-1], &asn1.CookStackElement{Value: serial})
+  return nil
+}
+
+// Replaces the file name on top of the stack with the serial number stored in
+// that file plus 1 and writes the new serial number back to the file.
+// If the file does not exist, it is created and the serial number is 1.
+// While the file is being updated, it is locked by creating a file with
+// the suffix ".lock". The new contents are written to a file with the suffix ".tmp"
+// which is then renamed so that the file is replaced atomically.
+func serialFile(stack_ *[]*asn1.CookStackElement, location string) error {
+  stack := *stack_
+  if len(stack) == 0 {
+    return fmt.Errorf("%vserial(file) called on empty stack", location)
+  }
+  file, ok := stack[len(stack)-1].Value.(string)
+  if !ok {
+    return fmt.Errorf("%vserial(file) requires top element of stack to be a file name", location)
+  }
+
+  lockfile := file + ".lock"
+  var lock *os.File
+  var err error
+  for try := 0; ; try++ {
+    lock, err = os.OpenFile(lockfile, os.O_WRONLY | os.O_CREATE | os.O_EXCL, 0644)
+    if err == nil { break }
+    if !os.IsExist(err) || try == 100 {
+      return fmt.Errorf("%vserial(file) error: Could not lock %v (if no other process is using it, remove %v): %v", location, file, lockfile, err)
+    }
+    time.Sleep(100*time.Millisecond)
+  }
+  lock.Close()
+  defer os.Remove(lockfile)
+
+  serial := new(big.Int)
+  data, err := ioutil.ReadFile(file)
+  if err == nil {
+    if _, ok := serial.SetString(strings.TrimSpace(string(data)), 10); !ok || serial.Sign() < 0 {
+      return fmt.Errorf("%vserial(file) error: %v does not contain a non-negative decimal number", location, file)
+    }
+  } else if !os.IsNotExist(err) {
+    return fmt.Errorf("%vserial(file) error: %v", location, err)
+  }
+  serial.Add(serial, big.NewInt(1))
+
+  tmpfile := file + ".tmp"
+  f, err := os.OpenFile(tmpfile, os.O_WRONLY | os.O_CREATE | os.O_TRUNC, 0644)
+  if err == nil {
+    _, err = util.WriteAll(f, []byte(serial.String() + "\n"))
+    if err2 := f.Close(); err == nil { err = err2 }
+  }
+  if err == nil {
+    err = os.Rename(tmpfile, file)
+  }
+  if err != nil {
+    os.Remove(tmpfile)
+    return fmt.Errorf("%vserial(file) error: %v", location, err)
+  }
+
+  *stack_ = append(stack[0:len(stack)-1], &asn1.CookStackElement{Value: serial})
+  return nil
+}
+
+// Pushes the current time (truncated to full seconds) as time.Time.
+func now(stack_ *[]*asn1.CookStackElement, location string) error {
+  *stack_ = append(*stack_, &asn1.CookStackElement{Value: time.Now().UTC().Truncate(time.Second)})
+  return nil
+}
+
+// Replaces the number on top of the stack with a time.Duration of that many days.
+func days(stack_ *[]*asn1.CookStackElement, location string) error {
+  stack := *stack_
+  if len(stack) == 0 {
+    return fmt.Errorf("%vdays() called on empty stack", location)
+  }
+
+  var n int64
+  switch parm := stack[len(stack)-1].Value.(type) {
+    case int:      n = int64(parm)
+    case float64:  if parm != math.Trunc(parm) || math.Abs(parm) > 1e6 {
+                     return fmt.Errorf("%vdays() called with illegal number of days: %v", location, parm)
+                   }
+                   n = int64(parm)
+    case *big.Int: if !parm.IsInt64() {
+                     return fmt.Errorf("%vdays() called with illegal number of days: %v", location, parm)
+                   }
+                   n = parm.Int64()
+    default: return fmt.Errorf("%vdays() called with parameter of unsupported type \"%T\"", location, parm)
+  }
+  if n > 1e6 || n < -1e6 {
+    return fmt.Errorf("%vdays() called with illegal number of days: %v", location, n)
+  }
+
+  *stack_ = append(stack[0:len(stack)-1], &asn1.CookStackElement{Value: time.Duration(n) * 24 * time.Hour})
+  return nil
+}
+
+// Replaces the top 2 elements of the stack with their sum. One of them must be
+// a time.Duration, the other may be a time.Duration or a time (see cookTime()).
+func add(stack_ *[]*asn1.CookStackElement, location string) error {
+  stack := *stack_
+  if len(stack) < 2 {
+    return fmt.Errorf("%vadd() requires 2 elements on the stack", location)
+  }
+
+  a := stack[len(stack)-2].Value
+  b := stack[len(stack)-1].Value
+  if _, ok := a.(time.Duration); ok {
+    a, b = b, a
+  }
+  d, ok := b.(time.Duration)
+  if !ok {
+    return fmt.Errorf("%vadd() requires a duration (e.g. from days()) on the stack", location)
+  }
+
+  var result interface{}
+  if d2, ok := a.(time.Duration); ok {
+    result = d + d2
+  } else if t, ok := cookTime(a); ok {
+    result = t.Add(d)
+  } else {
+    return fmt.Errorf("%vadd() called with parameter of unsupported type \"%T\"", location, a)
+  }
+
+  *stack_ = append(stack[0:len(stack)-2], &asn1.CookStackElement{Value: result})
+  return nil
+}
+
+func utcTime(stack_ *[]*asn1.CookStackElement, location string) error {
+  return timeInstance(stack_, location, "utcTime()", asn1.UTCTimeTag)
+}
+
+func generalizedTime(stack_ *[]*asn1.CookStackElement, location string) error {
+  return timeInstance(stack_, location, "generalizedTime()", asn1.GeneralizedTimeTag)
+}
+
+// Replaces the time (see cookTime()) on top of the stack with an Instance of
+// UTCTime or GeneralizedTime (depending on tag).
+func timeInstance(stack_ *[]*asn1.CookStackElement, location string, name string, tag int) error {
+  stack := *stack_
+  if len(stack) == 0 {
+    return fmt.Errorf("%v%v called on empty stack", location, name)
+  }
+  t, ok := cookTime(stack[len(stack)-1].Value)
+  if !ok {
+    return fmt.Errorf("%v%v requires a time (e.g. from now() or an RFC 3339 string) on top of the stack", location, name)
+  }
+  inst, err := asn1.TimeInstance(t, tag)
+  if err != nil {
+    return fmt.Errorf("%v%v error: %v", location, name, err)
+  }
+  *stack_ = append(stack[0:len(stack)-1], &asn1.CookStackElement{Value: inst})
+  return nil
+}
+
+// Converts value which must be a time.Time or a string accepted by asn1.ParseTime()
+// to a time.Time.
+func cookTime(value interface{}) (time.Time, bool) {
+  switch v := value.(type) {
+    case time.Time: return v, true
+    case string:    t, err := asn1.ParseTime(v)
+                    return t, err == nil
+  }
+  return time.Time{}, false
+}
+
+// Converts value which must be a non-negative number or *big.Int (or, if isTime is true,
+// a time or a string accepted by asn1.ParseTime(), e.g. "now+30d") to a uint64 as used
+// by OpenSSH certificates. Times are converted to seconds since the epoch.
+func sshNumber(value interface{}, isTime bool) (uint64, error) {
+  switch v := value.(type) {
+    case float64:
+        if v >= 0 && v < math.MaxUint64 && v == math.Trunc(v) {
+          return uint64(v), nil
+        }
+    case *big.Int:
+        if v.Sign() >= 0 && v.IsUint64() {
+          return v.Uint64(), nil
+        }
+    case time.Time:
+        if isTime && v.Unix() >= 0 {
+          return uint64(v.Unix()), nil
+        }
+    case string:
+        if isTime {
+          t, err := asn1.ParseTime(v)
+          if err == nil && t.Unix() >= 0 {
+            return uint64(t.Unix()), nil
+          }
+          return 0, fmt.Errorf("must be seconds since the epoch, an RFC 3339 time or a time relative to \"now\"")
+        }
+  }
+  return 0, fmt.Errorf("must be a non-negative integer")
+}
+
+// Converts value which must be an object whose members are all strings to a map.
+func sshOptions(value interface{}) (map[string]string, error) {
+  obj, ok := value.(map[string]interface{})
+  if !ok {
+    return nil, fmt.Errorf("must be an object")
+  }
+  options := map[string]string{}
+  for name, v := range obj {
+    if options[name], ok = v.(string); !ok {
+      return nil, fmt.Errorf("member \"%v\" must be a string", name)
+    }
+  }
+  return options, nil
+}
+
+// The ASN.1 definitions parsed by main().
+var defs asn1.Definitions
+
+var funcs = map[string]asn1.CookStackFunc{"encode(DER)":encodeDER, "encode(PEM)":encodePEM, "encode(base64)":encodeBase64, "encode(PKCS8)":encodePKCS8, "encode(PKCS8-PEM)":encodePKCS8PEM, "encode(SPKI-PEM)":encodeSPKIPEM, "encode(OpenSSH)":encodeOpenSSH, "encode(OpenSSH-private)":encodeOpenSSHPrivate, "encrypt(PKCS8)":encryptPKCS8, "decode(hex)":decodeHex, "decode(DER)":decodeDER, "write()": write, "write(if-missing)": write_if_missing, "write(append)": write_append, "key()": key, "cert()": cert, "subjectPublicKeyInfo()": subjectPublicKeyInfo, "sign()":sign, "sign(OpenSSH)":signOpenSSH, "keygen()": keygen, "keyIdentifier()": keyIdentifierSHA1, "keyIdentifier(SHA-256)": keyIdentifierSHA256, "serial(random)": serialRandom, "serial(hash)": serialHash, "serial(file)": serialFile, "now()": now, "days()": days, "add()": add, "utcTime()": utcTime, "generalizedTime()": generalizedTime}
 
 
 // Takes a JSON file and overwrites #... comments with spaces because
@@ -444,7 +1343,6 @@ func main() {
   }
   
   asn1.Debug = false
-  var defs asn1.Definitions
   
   /* parse definitions from RFC 5280 */
   if err := defs.Parse(rfc.PKIX1Explicit88); err != nil { panic(err) }
@@ -452,12 +1350,17 @@ func main() {
   if err := defs.Parse(rfc.KeyPurposeObsolete); err != nil { panic(err) }
   if err := defs.Parse(rfc.PKIX1Algorithms2008); err != nil { panic(err) }
   if err := defs.Parse(rfc.PKIX1_PSS_OAEP_Algorithms); err != nil { panic(err) }
+  if err := defs.Parse(rfc.Safecurves_pkix_18); err != nil { panic(err) }
   if err := defs.Parse(rfc.LogotypeCertExtension); err != nil { panic(err) }
   if err := defs.Parse(rfc.NetscapeExtensions); err != nil { panic(err) }
   if err := defs.Parse(rfc.EntrustExtensions); err != nil { panic(err) }
   if err := defs.Parse(rfc.MicrosoftExtensions); err != nil { panic(err) }
   if err := defs.Parse(rfc.SETExtensions); err != nil { panic(err) }
   if err := defs.Parse(rfc.GOsaExtensions); err != nil { panic(err) }
+  if err := defs.Parse(rfc.PKCS_10); err != nil { panic(err) }
+  if err := defs.Parse(rfc.AsymmetricKeyPackageModuleV1); err != nil { panic(err) }
+  if err := defs.Parse(rfc.PKCS_1); err != nil { panic(err) }
+  if err := defs.Parse(rfc.ECPrivateKeyStructure); err != nil { panic(err) }
   
   /* parse additional ASN.1 files */
   for _, arg := range os.Args[1:len(os.Args)-1] {
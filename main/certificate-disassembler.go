@@ -24,6 +24,10 @@ package main
 import (
          "os"
          "fmt"
+         "strings"
+         "unicode"
+         "io"
+         "bytes"
          "io/ioutil"
          "encoding/pem"
          
@@ -32,9 +36,46 @@ import (
 )
 
 
+// Maps PEM block types to the ASN.1 type of the structure contained in the block.
+var pemTypes = map[string]string{
+  "CERTIFICATE": "Certificate",
+  "CERTIFICATE REQUEST": "CertificationRequest",
+  "NEW CERTIFICATE REQUEST": "CertificationRequest",
+  "X509 CRL": "CertificateList",
+  "PRIVATE KEY": "PrivateKeyInfo",
+  "ENCRYPTED PRIVATE KEY": "EncryptedPrivateKeyInfo",
+  "PUBLIC KEY": "SubjectPublicKeyInfo",
+  "RSA PRIVATE KEY": "RSAPrivateKey",
+  "RSA PUBLIC KEY": "RSAPublicKey",
+  "EC PRIVATE KEY": "ECPrivateKey",
+}
+
+// ASN.1 types that are tried in order if the structure's type is not known
+// from the PEM block type and no -type option has been passed.
+var candidateTypes = []string{
+  "Certificate",
+  "CertificateList",
+  "CertificationRequest",
+  "PrivateKeyInfo",
+  "EncryptedPrivateKeyInfo",
+  "SubjectPublicKeyInfo",
+  "RSAPrivateKey",
+  "RSAPublicKey",
+  "ECPrivateKey",
+}
+
+// Returns the name of the JSON variable that holds data of type typename,
+// e.g. "certificate" for "Certificate" and "rsaPrivateKey" for "RSAPrivateKey".
+func variableName(typename string) string {
+  i := 0
+  for i < len(typename) && unicode.IsUpper(rune(typename[i])) { i++ }
+  if i > 1 && i < len(typename) { i-- } // keep the 1st capital of the next word
+  return strings.ToLower(typename[:i]) + typename[i:]
+}
+
 func main() {
   if len(os.Args) < 2 {
-    fmt.Fprintf(os.Stderr, "USAGE: %v [<syntax.asn1> ...] input.cert \n", "certificate-disassembler")
+    fmt.Fprintf(os.Stderr, "USAGE: %v [-type <Name> ...] [<syntax.asn1> ...] input.cert \n", "certificate-disassembler")
     os.Exit(1)
   }
   
@@ -47,17 +88,35 @@ func main() {
   if err := defs.Parse(rfc.KeyPurposeObsolete); err != nil { panic(err) }
   if err := defs.Parse(rfc.PKIX1Algorithms2008); err != nil { panic(err) }
   if err := defs.Parse(rfc.PKIX1_PSS_OAEP_Algorithms); err != nil { panic(err) }
+  if err := defs.Parse(rfc.Safecurves_pkix_18); err != nil { panic(err) }
   if err := defs.Parse(rfc.LogotypeCertExtension); err != nil { panic(err) }
   if err := defs.Parse(rfc.NetscapeExtensions); err != nil { panic(err) }
   if err := defs.Parse(rfc.EntrustExtensions); err != nil { panic(err) }
   if err := defs.Parse(rfc.MicrosoftExtensions); err != nil { panic(err) }
   if err := defs.Parse(rfc.SETExtensions); err != nil { panic(err) }
   if err := defs.Parse(rfc.GOsaExtensions); err != nil { panic(err) }
+  if err := defs.Parse(rfc.PKCS_10); err != nil { panic(err) }
+  if err := defs.Parse(rfc.AsymmetricKeyPackageModuleV1); err != nil { panic(err) }
+  if err := defs.Parse(rfc.PKCS_1); err != nil { panic(err) }
+  if err := defs.Parse(rfc.ECPrivateKeyStructure); err != nil { panic(err) }
   
   if err := defs.Parse(rfc.DisassemblerMappings); err != nil { panic(err) }
   
-  /* parse additional ASN.1 files */
-  for _, arg := range os.Args[1:len(os.Args)-1] {
+  /* parse -type options and additional ASN.1 files */
+  types := []string{}
+  args := os.Args[1:len(os.Args)-1]
+  for i := 0; i < len(args); i++ {
+    arg := args[i]
+    if arg == "-type" {
+      i++
+      if i == len(args) {
+        fmt.Fprintf(os.Stderr, "-type requires an ASN.1 type name as argument\n")
+        os.Exit(1)
+      }
+      types = append(types, args[i])
+      continue
+    }
+    
     data, err := ioutil.ReadFile(arg)
     if err != nil {
       fmt.Fprintf(os.Stderr, "%v: %v\n", arg, err)
@@ -71,7 +130,14 @@ func main() {
     }
   }
   
-  /* parse PEM input */
+  for _, typename := range types {
+    if !defs.HasType(typename) {
+      fmt.Fprintf(os.Stderr, "-type %v: Unknown type\n", typename)
+      os.Exit(1)
+    }
+  }
+  
+  /* read input, which may be PEM blocks or binary or base64 encoded DER SEQUENCEs */
   filename := os.Args[len(os.Args)-1]
   data, err := ioutil.ReadFile(filename)
   if err != nil {
@@ -79,26 +145,123 @@ func main() {
     os.Exit(1)
   }
   
-  block, rest := pem.Decode(data)
-  if len(rest) != 0 {
-    fmt.Fprintf(os.Stderr, "Could not decode PEM: Garbage at end of file:\n%v\n", string(rest))
-    os.Exit(1)
+  blocks := []*pem.Block{}
+  encoding := "PEM"
+  for rest := data; ; {
+    var block *pem.Block
+    block, rest = pem.Decode(rest)
+    if block == nil {
+      if len(blocks) > 0 && len(bytes.TrimSpace(rest)) != 0 {
+        fmt.Fprintf(os.Stderr, "Could not decode PEM: Garbage at end of file:\n%v\n", string(rest))
+        os.Exit(1)
+      }
+      break
+    }
+    blocks = append(blocks, block)
   }
-    
-  data = block.Bytes
-  unmarshaled := asn1.UnmarshalDER(data, 0)
-  if unmarshaled == nil {
-    fmt.Fprintf(os.Stderr, "Could not unmarshal DER data\n")
-    os.Exit(1)
+  
+  // Not PEM => binary DER or bare base64. ReadNextSEQUENCE() handles both.
+  // No SEQUENCE can be longer than the file, so garbage that looks like the
+  // start of a larger one is dropped right away instead of being tracked until EOF.
+  if len(blocks) == 0 {
+    encoding = "DER"
+    in := bytes.NewReader(data)
+    for {
+      der, err := asn1.ReadNextSEQUENCELimited(in, len(data))
+      if err == io.EOF {
+        break
+      }
+      if err != nil {
+        fmt.Fprintf(os.Stderr, "%v\n", err)
+        os.Exit(1)
+      }
+      if !bytes.Contains(data, der) {
+        encoding = "base64"
+      }
+      blocks = append(blocks, &pem.Block{Bytes: der})
+    }
   }
-  unmarshaled = unmarshaled.Data[asn1.Rawtag([]byte{0x30})].(*asn1.UnmarshalledConstructed)
-
-  output, err := defs.Instantiate("Certificate", unmarshaled)
-  if err != nil {
-    fmt.Fprintf(os.Stderr, "%v\n", err)
+  
+  if len(blocks) == 0 {
+    fmt.Fprintf(os.Stderr, "%v: No PEM block, DER SEQUENCE or base64 encoded DER SEQUENCE found\n", filename)
     os.Exit(1)
   }
   
-  fmt.Fprintf(os.Stdout, "{\n  \"certificate\": %v,\n  \"output\": \"$certificate Certificate encode(PEM) '%v' write()\"\n}\n", 
-    output.JSON(asn1.LinePrefix("  "), asn1.InlineStructMax(70), defs.OIDNames(), defs.DERinDER()), filename)
+  /* disassemble each block into its own variable with its own output program */
+  // The JSON is only printed once all blocks have been disassembled,
+  // so that an error in a later block does not leave a truncated program on stdout.
+  var out bytes.Buffer
+  out.WriteString("{\n")
+  suffix := ""
+  write := "write()"
+  outputs := []string{}
+  for i, block := range blocks {
+    if len(blocks) > 1 { // number the variables so that they are processed in order
+      suffix = fmt.Sprintf("%0*d", len(fmt.Sprintf("%d", len(blocks))), i+1)
+    }
+    
+    // -type options take precedence over the PEM block type,
+    // which takes precedence over the list of candidates.
+    btypes := types
+    if len(btypes) == 0 {
+      if typename, ok := pemTypes[block.Type]; ok {
+        btypes = []string{typename}
+      } else {
+        btypes = candidateTypes
+      }
+    }
+    
+    // BER is accepted, too. The generated JSON will produce DER.
+    unmarshaled, err := asn1.UnmarshalBERWithError(block.Bytes, 0)
+    if err != nil {
+      if len(blocks) > 1 {
+        fmt.Fprintf(os.Stderr, "Block %v: ", i+1)
+      }
+      fmt.Fprintf(os.Stderr, "Could not unmarshal DER/BER data: %v\n", err)
+      os.Exit(1)
+    }
+    
+    top, count := unmarshaled.First()
+    if count != 1 {
+      if len(blocks) > 1 {
+        fmt.Fprintf(os.Stderr, "Block %v: ", i+1)
+      }
+      fmt.Fprintf(os.Stderr, "Data contains %v DER elements instead of 1\n", count)
+      os.Exit(1)
+    }
+    
+    var output *asn1.Instance
+    errs := []string{}
+    typename := ""
+    for _, typename = range btypes {
+      output, err = defs.Instantiate(typename, top)
+      if err == nil {
+        break
+      }
+      errs = append(errs, fmt.Sprintf("%v: %v", typename, err))
+    }
+    
+    if err != nil {
+      if len(blocks) > 1 {
+        fmt.Fprintf(os.Stderr, "Block %v: ", i+1)
+      }
+      if len(errs) == 1 {
+        fmt.Fprintf(os.Stderr, "%v\n", err)
+      } else {
+        fmt.Fprintf(os.Stderr, "Data does not match any of the candidate types:\n%v\n", strings.Join(errs, "\n"))
+      }
+      os.Exit(1)
+    }
+    
+    variable := variableName(typename) + suffix
+    fmt.Fprintf(&out, "  \"%v\": %v,\n", 
+      variable, output.JSON(asn1.LinePrefix("  "), asn1.InlineStructMax(70), defs.OIDNames(), defs.DERinDER()))
+    outputs = append(outputs, fmt.Sprintf("  \"output%v\": \"$%v %v encode(%v) '%v' %v\"", suffix, variable, typename, encoding, filename, write))
+    
+    // all blocks after the 1st are appended to the same file
+    write = "write(append)"
+  }
+  
+  fmt.Fprintf(&out, "%v\n}\n", strings.Join(outputs, ",\n"))
+  os.Stdout.Write(out.Bytes())
 }
@@ -74,6 +74,28 @@ id-Extension-extnValue-netscapeSSLserverName OBJECT IDENTIFIER ::= id-netscapeSS
 Extension-extnValue-netscapeSSLserverName ::= NetscapeSSLserverName
 id-Extension-extnValue-netscapeComment OBJECT IDENTIFIER ::= id-netscapeComment
 Extension-extnValue-netscapeComment ::= NetscapeComment
+id-Attribute-values-extensionRequest OBJECT IDENTIFIER ::= pkcs-9-at-extensionRequest
+Attribute-values-extensionRequest ::= ExtensionRequest
+id-Attribute-values-challengePassword OBJECT IDENTIFIER ::= pkcs-9-at-challengePassword
+Attribute-values-challengePassword ::= ChallengePassword
+id-PrivateKeyInfo-privateKey-rsa OBJECT IDENTIFIER ::= rsaEncryption
+PrivateKeyInfo-privateKey-rsa ::= RSAPrivateKey
+id-PrivateKeyInfo-privateKey-secp224r1 OBJECT IDENTIFIER ::= secp224r1
+PrivateKeyInfo-privateKey-secp224r1 ::= ECPrivateKey
+id-PrivateKeyInfo-privateKey-secp256r1 OBJECT IDENTIFIER ::= secp256r1
+PrivateKeyInfo-privateKey-secp256r1 ::= ECPrivateKey
+id-PrivateKeyInfo-privateKey-secp384r1 OBJECT IDENTIFIER ::= secp384r1
+PrivateKeyInfo-privateKey-secp384r1 ::= ECPrivateKey
+id-PrivateKeyInfo-privateKey-secp521r1 OBJECT IDENTIFIER ::= secp521r1
+PrivateKeyInfo-privateKey-secp521r1 ::= ECPrivateKey
+id-PrivateKeyInfo-privateKey-ed25519 OBJECT IDENTIFIER ::= id-Ed25519
+PrivateKeyInfo-privateKey-ed25519 ::= CurvePrivateKey
+id-PrivateKeyInfo-privateKey-ed448 OBJECT IDENTIFIER ::= id-Ed448
+PrivateKeyInfo-privateKey-ed448 ::= CurvePrivateKey
+id-PrivateKeyInfo-privateKey-x25519 OBJECT IDENTIFIER ::= id-X25519
+PrivateKeyInfo-privateKey-x25519 ::= CurvePrivateKey
+id-PrivateKeyInfo-privateKey-x448 OBJECT IDENTIFIER ::= id-X448
+PrivateKeyInfo-privateKey-x448 ::= CurvePrivateKey
 
 
 END
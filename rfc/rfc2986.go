@@ -0,0 +1,26 @@
+package rfc
+const PKCS_10 = `
+DEFINITIONS IMPLICIT TAGS ::= BEGIN
+pkcs-9-at-challengePassword OBJECT IDENTIFIER ::= { pkcs-9 7 }
+pkcs-9-at-extensionRequest OBJECT IDENTIFIER ::= { pkcs-9 14 }
+
+CertificationRequestInfo ::= SEQUENCE {
+ version INTEGER { v1(0) },
+ subject Name,
+ subjectPKInfo SubjectPublicKeyInfo,
+ attributes [0] Attributes
+}
+
+Attributes ::= SET OF Attribute
+
+CertificationRequest ::= SEQUENCE {
+ certificationRequestInfo CertificationRequestInfo,
+ signatureAlgorithm AlgorithmIdentifier,
+ signature BIT STRING
+}
+
+ChallengePassword ::= DirectoryString
+ExtensionRequest ::= Extensions
+
+END
+`
@@ -0,0 +1,26 @@
+package rfc
+const AsymmetricKeyPackageModuleV1 = `
+DEFINITIONS IMPLICIT TAGS ::= BEGIN
+PrivateKeyInfo ::= SEQUENCE {
+ version INTEGER { v1(0), v2(1) },
+ privateKeyAlgorithm PrivateKeyAlgorithmIdentifier,
+ privateKey PrivateKey,
+ attributes [0] Attributes OPTIONAL,
+ publicKey [1] PublicKey OPTIONAL
+}
+
+OneAsymmetricKey ::= PrivateKeyInfo
+PrivateKeyAlgorithmIdentifier ::= AlgorithmIdentifier
+PrivateKey ::= OCTET STRING
+PublicKey ::= BIT STRING
+
+EncryptedPrivateKeyInfo ::= SEQUENCE {
+ encryptionAlgorithm EncryptionAlgorithmIdentifier,
+ encryptedData EncryptedData
+}
+
+EncryptionAlgorithmIdentifier ::= AlgorithmIdentifier
+EncryptedData ::= OCTET STRING
+
+END
+`
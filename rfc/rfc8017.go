@@ -0,0 +1,26 @@
+package rfc
+const PKCS_1 = `
+DEFINITIONS EXPLICIT TAGS ::= BEGIN
+RSAPrivateKey ::= SEQUENCE {
+ version INTEGER { two-prime(0), multi(1) },
+ modulus INTEGER,
+ publicExponent INTEGER,
+ privateExponent INTEGER,
+ prime1 INTEGER,
+ prime2 INTEGER,
+ exponent1 INTEGER,
+ exponent2 INTEGER,
+ coefficient INTEGER,
+ otherPrimeInfos OtherPrimeInfos OPTIONAL
+}
+
+OtherPrimeInfos ::= SEQUENCE SIZE(1..MAX) OF OtherPrimeInfo
+
+OtherPrimeInfo ::= SEQUENCE {
+ prime INTEGER,
+ exponent INTEGER,
+ coefficient INTEGER
+}
+
+END
+`
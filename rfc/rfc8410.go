@@ -0,0 +1,33 @@
+package rfc
+const Safecurves_pkix_18 = `
+DEFINITIONS EXPLICIT TAGS ::= BEGIN
+id-edwards-curve-algs OBJECT IDENTIFIER ::= { iso(1) identified-organization(3) thawte(101) }
+id-X25519 OBJECT IDENTIFIER ::= { id-edwards-curve-algs 110 }
+id-X448 OBJECT IDENTIFIER ::= { id-edwards-curve-algs 111 }
+id-Ed25519 OBJECT IDENTIFIER ::= { id-edwards-curve-algs 112 }
+id-Ed448 OBJECT IDENTIFIER ::= { id-edwards-curve-algs 113 }
+
+-- The information objects of RFC 8410 cannot be expressed in this syntax.
+-- What they specify:
+--
+-- sa-Ed25519, sa-Ed448 (SIGNATURE-ALGORITHM):
+--   The signature AlgorithmIdentifier is { algorithm id-Ed25519 } or
+--   { algorithm id-Ed448 } with the parameters absent.
+--
+-- pk-Ed25519, pk-Ed448, pk-X25519, pk-X448 (PUBLIC-KEY):
+--   The SubjectPublicKeyInfo algorithm is the curve OID with the parameters
+--   absent. The subjectPublicKey BIT STRING contains the raw public key
+--   without ASN.1 wrapping. The privateKey OCTET STRING of a OneAsymmetricKey
+--   contains a CurvePrivateKey.
+--   CERT-KEY-USAGE of pk-Ed25519 and pk-Ed448: digitalSignature, nonRepudiation,
+--   keyCertSign, cRLSign.
+--   CERT-KEY-USAGE of pk-X25519 and pk-X448: keyAgreement, encipherOnly, decipherOnly.
+--
+-- kaa-X25519, kaa-X448 (KEY-AGREE):
+--   The key agreement AlgorithmIdentifier is { algorithm id-X25519 } or
+--   { algorithm id-X448 } with the parameters absent.
+
+CurvePrivateKey ::= OCTET STRING
+
+END
+`
@@ -6,6 +6,7 @@ import (
          "crypto/x509"
          "crypto/rsa"
          "crypto/ecdsa"
+         "crypto/ed25519"
          
          "../asn1"
        )
@@ -49,6 +50,7 @@ func main() {
     switch key := key3.(type){
       case *rsa.PrivateKey: pub = key.Public()
       case *ecdsa.PrivateKey: pub = key.Public()
+      case ed25519.PrivateKey: pub = key.Public()
     }
   }
   